@@ -89,7 +89,7 @@ func fixtureResourcesJson() (*asset, error) {
 	return a, nil
 }
 
-var _fixtureSpecYml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x53\x41\x6e\xdb\x40\x0c\xbc\xfb\x15\x84\xee\xae\xd1\x5e\x5a\xe8\xd8\xf6\x9e\xa0\x79\x01\xbd\x3b\x92\x08\x48\x4b\x85\x5c\xa9\xf5\xef\x0b\xad\x62\x3b\x80\x75\x48\xec\x1b\x31\x24\x66\x66\xc9\xd9\xfd\x7e\xbf\x73\xd8\x2c\x01\x5e\xef\x88\xaa\x53\x23\xa9\x5a\x2a\xa2\x91\x73\xe7\x6b\x49\x54\x1d\xe6\xef\x87\x46\x12\xa7\x80\xc3\xeb\xa4\x19\xd5\xb9\xb5\x4c\x1a\x0f\xc8\x30\xbf\x62\x7b\x8a\xf0\x60\x32\x66\xd1\x54\x53\xf5\x32\x22\x48\x23\x70\xfa\xdb\x49\xe8\xc8\x4f\xc3\x51\x7b\xa7\xac\x34\x9a\xce\x12\x41\x85\xd6\xa9\x51\xfb\x52\x5d\x78\x88\x12\x0f\xa8\xcf\xf3\xef\x70\xc3\xeb\x24\x86\x58\x53\xb6\x09\xbb\x2b\xec\x3a\x59\x40\xbd\xf2\x5d\xfd\xff\xb8\xf8\x0f\x1d\x5b\xbe\xdb\xff\x11\xad\xa4\x24\xa9\x25\x6d\x28\x77\xa0\x2c\x03\xc8\x61\x02\xbf\xf5\x3d\xc2\x44\xe3\xd7\x4d\xdf\x0d\xf7\x8e\x0f\x28\x2e\x22\x48\xf1\x13\x7a\xdf\x1e\xd2\x5b\x2f\xc4\x6d\x6b\x68\x79\xe9\xbe\xb1\x12\x38\x74\x54\xb6\x47\x47\x36\x0a\x3a\xc3\x36\x3c\x48\xca\xb0\x99\xfb\x3b\x4c\xfc\x54\xed\x97\x4c\x48\x0a\xfd\x14\x41\xa3\x81\x38\x45\x1a\xd5\x33\x39\xdc\x45\xd3\xa6\x62\x19\x7f\x36\x3c\xab\xe7\x87\x1e\x5f\x52\x43\x86\x56\x34\xdd\x0a\xad\xf8\x1d\x02\xbf\x9e\xfe\xbc\x50\xd4\x81\x65\x83\x35\xa8\xf9\xef\xd2\xfb\x00\xf3\x35\xe0\xe5\x12\x5b\x1f\x54\x8b\xaa\x3f\xf0\x45\x91\x3b\xd8\x72\x88\x46\x6d\xe0\x5c\x72\x67\xf0\x51\x93\x83\xd8\xc9\xb3\x71\x8c\xfd\x56\x02\xcf\xad\x3b\x96\xf4\x54\x0a\xee\xfb\x13\xf9\xc5\x0c\x27\xc2\xbf\x51\x6c\x4d\x62\xe4\x8c\x5b\xcd\x05\xfd\xd4\xea\xde\x36\xb4\xfb\x1f\x00\x00\xff\xff\x38\xfc\x70\x84\x01\x05\x00\x00")
+var _fixtureSpecYml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xb5\x54\x4d\x6f\xdb\x30\x0c\xbd\xe7\x57\x10\x39\xcf\x4b\xdd\x61\x5f\x3e\x6e\xbb\xb7\x58\x8f\xc3\x0e\x8a\x44\xdb\x42\xf5\x55\x51\x76\x9b\x7f\x3f\x4a\x69\x9a\xb8\x70\x56\x0f\xed\x0e\x22\x6c\x3d\x89\x7c\xe4\x23\x55\x55\xd5\x8a\x30\x8e\x5a\x22\x35\x2b\x80\xf5\xae\xd5\x6e\x9d\xbf\x00\x82\x48\x3d\xed\x3f\x19\xd8\x8c\x9f\x37\x8c\x09\x27\x71\x73\x37\xf8\x84\xeb\x03\x04\xd0\x61\x3a\xfe\xe4\x8b\x51\x58\x4c\x18\xe9\x74\xb7\x02\x85\x24\xa3\x0e\x49\x7b\xd7\xc0\xfa\x26\xa0\xd4\xad\x46\x82\xfb\x5e\xcb\x1e\x68\x67\xb7\xde\x10\x24\x0f\x21\xfa\x51\x2b\x84\x12\x87\xa0\xf5\xf1\xfd\xfa\xc4\x13\x80\x63\xff\xcd\xe1\xc6\x04\x89\x78\x37\xe8\x88\xaa\x81\x14\x07\x9c\x40\x69\x17\xf8\x92\xa4\xb1\x32\x9a\xd2\x04\xb2\x9a\x19\xd5\xab\x53\x37\xe4\x87\x28\xf9\x7c\xe1\x70\x2c\xc2\x97\xa7\x22\xc8\x5e\xc4\xf4\x76\x45\xd8\x62\xa7\x9d\xd3\xae\x03\xdf\x42\xea\x11\x92\xb6\x08\xac\x0d\x83\x73\xc9\x07\x46\xbc\xaa\xcf\x24\xdf\x0a\x43\x73\xd9\x63\xf0\xb2\x5f\xc4\x27\x53\x40\xa7\xfe\x89\xcd\xe5\x7f\x63\xb3\x6f\x11\xd1\x75\x11\x3b\x91\xd1\xc7\x88\x80\x82\xf7\x8b\x14\xb0\x15\x11\xa4\x1f\xb9\xe2\x73\x0c\xb5\x63\x2d\x46\x61\x16\x53\x44\x37\xd8\x06\x7e\xd5\xf6\x1d\x5c\xf2\xfa\xc8\xab\xce\xe6\xc3\x05\x9b\x4f\xd9\x7c\xcd\xa6\xee\x79\x29\xc6\x79\xd5\xf7\xb7\x6c\xac\xe7\x43\xd6\xff\xfe\x4b\x62\xdf\xbc\x37\xb9\xd1\xb5\x93\x66\xe0\x46\x0f\x11\x41\x70\xb1\x83\xa7\xc4\x55\x26\xe2\x63\x67\xb2\x28\x17\xae\x23\x5e\xfb\x67\x3d\xfc\x72\xb9\xb7\x1c\x75\x51\xb5\x4b\xcf\xb3\xc3\x8e\x81\x39\x16\x7b\x64\x61\xf4\xe7\x61\xbe\x5f\xfd\xbc\x01\xe5\xad\xd0\xb3\xbe\xa5\x8f\xf4\xa3\xa0\x0b\xfd\x1f\x47\xb5\xb4\xc1\xe9\x08\x46\x6c\xf5\xc3\xe4\x25\x98\x3c\x63\xbe\x50\xa2\xb7\x7c\xc8\x90\x67\x25\x66\x65\xf9\xcd\xb2\x22\x95\xd1\x61\x82\x81\xc3\xb0\xc2\x04\x94\xa2\x50\xca\xcc\x0f\xd1\x01\x7c\x9d\xac\xc0\xf4\x5a\x31\x98\xf4\xb2\x14\x57\xe5\x43\x18\xb3\x03\x7a\x4a\x42\x38\xc0\x87\xa0\xe3\x7e\xcc\x94\xe0\x97\x7e\x86\x6b\xde\x7f\xe5\xb4\x1f\x85\x7b\x14\xe2\x9c\x74\x7f\x00\x06\xc6\x03\x3a\xa5\x06\x00\x00")
 
 func fixtureSpecYmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -104,7 +104,7 @@ func fixtureSpecYml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "fixture/spec.yml", size: 1281, mode: os.FileMode(420), modTime: time.Unix(1531601204, 0)}
+	info := bindataFileInfo{name: "fixture/spec.yml", size: 1701, mode: os.FileMode(420), modTime: time.Unix(1792404721, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
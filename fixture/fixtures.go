@@ -1,5 +1,11 @@
 package fixture
 
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
 const (
 	// YFinQuotes are the yfin quote responses.
 	YFinQuotes ResourceID = "quote"
@@ -9,6 +15,50 @@ const (
 	YFinOptions ResourceID = "options"
 	// ServiceYFin is the yfin service.
 	ServiceYFin ServiceID = "yfin"
+
+	// ParamString is a parameter taken as it is.
+	ParamString ParamType = "string"
+	// ParamInt is an integer parameter.
+	ParamInt ParamType = "int"
+	// ParamBool is a true or false parameter.
+	ParamBool ParamType = "bool"
+	// ParamEpoch is a time in unix epoch seconds.
+	ParamEpoch ParamType = "epoch"
+	// ParamList is a comma separated list of values.
+	ParamList ParamType = "csv-list"
+
+	// LatencyFixed always delays by the configured delay.
+	LatencyFixed LatencyDistribution = "fixed"
+	// LatencyUniform delays by the delay plus or minus a uniform jitter.
+	LatencyUniform LatencyDistribution = "uniform"
+	// LatencyNormal delays by a normal distribution around the delay.
+	LatencyNormal LatencyDistribution = "normal"
+	// LatencyLogNormal delays by a log-normal distribution with the delay as mean.
+	LatencyLogNormal LatencyDistribution = "lognormal"
+
+	// FaultBodyJSON answers faults with the yfin error envelope.
+	FaultBodyJSON FaultBody = "json"
+	// FaultBodyHTML answers faults with an edge server error page.
+	FaultBodyHTML FaultBody = "html"
+
+	// ChaosTruncate closes the connection halfway through the body.
+	ChaosTruncate ChaosMode = "truncate"
+	// ChaosInvalidJSON sends a body that doesn't decode.
+	ChaosInvalidJSON ChaosMode = "invalid-json"
+	// ChaosContentLength announces more body than is sent.
+	ChaosContentLength ChaosMode = "content-length"
+	// ChaosReset resets the connection before responding.
+	ChaosReset ChaosMode = "reset"
+	// ChaosHang sends the headers and then never the body.
+	ChaosHang ChaosMode = "hang"
+
+	// RateLimitByIP keeps a token bucket per client ip.
+	RateLimitByIP = "ip"
+	// RateLimitByCrumb keeps a token bucket per crumb query parameter.
+	RateLimitByCrumb = "crumb"
+	// RateLimitByHeaderPrefix keeps a token bucket per value of the named
+	// header, e.g. header:X-Api-Key.
+	RateLimitByHeaderPrefix = "header:"
 )
 
 // Path is a url path.
@@ -30,23 +80,280 @@ type Fixtures struct {
 
 // Spec specification of services.
 type Spec struct {
-	Services map[ServiceID]*Service `yaml:"services"`
+	Services  map[ServiceID]*Service `yaml:"services"`
+	Scenarios []*Scenario            `yaml:"scenarios"`
+	Stubs     []*Stub                `yaml:"stubs"`
 }
 
 // Service is a collection of url paths and resources.
 type Service struct {
-	Paths map[Path]*Operation `yaml:"paths"`
+	Paths map[Path]PathItem `yaml:"paths"`
 }
 
-// Operation defines a service operation.
+// PathItem maps the HTTP methods of a path, in upper case, to their
+// operations.
+type PathItem map[string]*Operation
+
+// httpMethods are the methods a path item may declare.
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "TRACE"}
+
+// UnmarshalYAML reads the operations of a path keyed by method, e.g. get. A
+// path written as a single operation, as in older specs, takes GET.
+func (p *PathItem) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var keys map[string]interface{}
+	err := unmarshal(&keys)
+	if err != nil {
+		return err
+	}
+
+	byMethod := len(keys) > 0
+	for k := range keys {
+		if !isHTTPMethod(k) {
+			byMethod = false
+			break
+		}
+	}
+	if !byMethod {
+		var op Operation
+		err = unmarshal(&op)
+		if err != nil {
+			return err
+		}
+		*p = PathItem{"GET": &op}
+		return nil
+	}
+
+	var ops map[string]*Operation
+	err = unmarshal(&ops)
+	if err != nil {
+		return err
+	}
+	*p = make(PathItem, len(ops))
+	for method, op := range ops {
+		if op == nil {
+			op = &Operation{}
+		}
+		(*p)[strings.ToUpper(method)] = op
+	}
+	return nil
+}
+
+func isHTTPMethod(s string) bool {
+	for _, m := range httpMethods {
+		if strings.EqualFold(m, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Operation gets the operation serving a method. HEAD requests are served by
+// GET unless declared.
+func (p PathItem) Operation(method string) *Operation {
+	if op, ok := p[method]; ok {
+		return op
+	}
+	if method == "HEAD" {
+		return p["GET"]
+	}
+	return nil
+}
+
+// Allow lists the methods a path answers, including HEAD where GET is
+// declared and OPTIONS.
+func (p PathItem) Allow() []string {
+	var allow []string
+	for _, m := range httpMethods {
+		if p.Operation(m) != nil || m == "OPTIONS" {
+			allow = append(allow, m)
+		}
+	}
+	return allow
+}
+
+// Operation defines a service operation. Operations without a resource are
+// answered with their example response. Prefix, when true, routes the
+// operation's path for the paths it extends by whole segments too, not only
+// for itself.
 type Operation struct {
 	Parameters []*Parameter `yaml:"parameters"`
 	ResourceID ResourceID   `yaml:"resource"`
+	Example    *Step        `yaml:"example"`
+	Lookup     *Lookup      `yaml:"lookup"`
+	Latency    *Latency     `yaml:"latency"`
+	Faults     []*Fault     `yaml:"faults"`
+	RateLimit  *RateLimit   `yaml:"ratelimit"`
+	Chaos      *Chaos       `yaml:"chaos"`
+	Throttle   *Throttle    `yaml:"throttle"`
+	Prefix     *bool        `yaml:"prefix"`
+}
+
+// Lookup describes how a generic service finds the response of an operation in
+// its resource tree. Each key, path.name or query.name, names a level of the
+// tree, and a list parameter looks up each of its items. The result is written
+// into the envelope where it holds "{{result}}". Misses serve the node at the
+// slash separated Miss path of the tree with MissStatus, 404 by default.
+type Lookup struct {
+	Key        []string    `yaml:"key" json:"key"`
+	Envelope   interface{} `yaml:"envelope" json:"envelope,omitempty"`
+	Miss       string      `yaml:"miss" json:"miss,omitempty"`
+	MissStatus int         `yaml:"missStatus" json:"missStatus,omitempty"`
 }
 
-// Parameter describes a url parameter.
+// ParamType is the type a url parameter's value is read as.
+type ParamType string
+
+// Parameter describes a url parameter. Enum and Pattern constrain each value,
+// or each item of a list. Min and Max bound numbers, the length of strings and
+// the number of items in a list. Default is used when the parameter is
+// missing.
 type Parameter struct {
-	Description string `yaml:"description"`
-	Name        string `yaml:"name"`
-	Required    bool   `yaml:"required"`
+	Description string    `yaml:"description"`
+	Name        string    `yaml:"name"`
+	Required    bool      `yaml:"required"`
+	Type        ParamType `yaml:"type"`
+	Enum        []string  `yaml:"enum"`
+	Default     string    `yaml:"default"`
+	Pattern     string    `yaml:"pattern"`
+	Min         *float64  `yaml:"min"`
+	Max         *float64  `yaml:"max"`
+}
+
+// LatencyDistribution is the shape of an artificial response delay.
+type LatencyDistribution string
+
+// Latency describes an artificial response delay. Jitter is the spread of the
+// uniform distribution or the standard deviation of the normal ones.
+type Latency struct {
+	Distribution LatencyDistribution `yaml:"distribution" json:"distribution"`
+	Delay        Duration            `yaml:"delay" json:"delay"`
+	Jitter       Duration            `yaml:"jitter" json:"jitter"`
+	Seed         int64               `yaml:"seed" json:"seed"`
+}
+
+// FaultBody is the kind of body sent with an injected fault.
+type FaultBody string
+
+// Fault describes the share of responses replaced by server errors. Symbols
+// limits the fault to requests for those symbols.
+type Fault struct {
+	Rate     float64   `yaml:"rate" json:"rate"`
+	Statuses []int     `yaml:"statuses" json:"statuses"`
+	Body     FaultBody `yaml:"body" json:"body"`
+	Symbols  []string  `yaml:"symbols" json:"symbols,omitempty"`
+	Seed     int64     `yaml:"seed" json:"seed"`
+}
+
+// RateLimit describes a token bucket refilled by Rate tokens per second and
+// holding at most Burst tokens.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+	Key   string  `yaml:"key" json:"key"`
+}
+
+// Scenario is an ordered script of responses for requests to a path, and
+// optionally for one symbol. Once its steps are used up requests are served
+// from fixtures again.
+type Scenario struct {
+	Name   string  `yaml:"name" json:"name"`
+	Path   Path    `yaml:"path" json:"path"`
+	Symbol string  `yaml:"symbol" json:"symbol,omitempty"`
+	Steps  []*Step `yaml:"steps" json:"steps"`
+}
+
+// Step is a scripted response repeated Repeat times. A string body is sent as
+// is, any other body as json. Steps without a body and with a 2xx status are
+// served from fixtures.
+type Step struct {
+	Repeat  int               `yaml:"repeat" json:"repeat,omitempty"`
+	Status  int               `yaml:"status" json:"status,omitempty"`
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+	Body    interface{}       `yaml:"body" json:"body,omitempty"`
+}
+
+// ChaosMode is a way of breaking the transport of a response.
+type ChaosMode string
+
+// Chaos describes how the responses of a path are broken in transport.
+type Chaos struct {
+	Mode ChaosMode `yaml:"mode" json:"mode"`
+}
+
+// Throttle describes a slow connection streaming the body in chunks of
+// ChunkSize bytes at Rate bytes per second, flushed every FlushInterval.
+type Throttle struct {
+	Rate          int      `yaml:"rate" json:"rate"`
+	ChunkSize     int      `yaml:"chunk" json:"chunk"`
+	FlushInterval Duration `yaml:"flush" json:"flush"`
+}
+
+// Stub is a canned response for requests matching its request matcher. Lower
+// priorities are consulted first.
+type Stub struct {
+	Name     string       `yaml:"name" json:"name"`
+	Priority int          `yaml:"priority" json:"priority"`
+	Request  *StubRequest `yaml:"request" json:"request"`
+	Response *Step        `yaml:"response" json:"response"`
+}
+
+// StubRequest matches requests. Path is a regular expression matched against
+// the whole path, empty fields match anything.
+type StubRequest struct {
+	Method  string                   `yaml:"method" json:"method,omitempty"`
+	Path    string                   `yaml:"path" json:"path,omitempty"`
+	Query   map[string]*ValueMatcher `yaml:"query" json:"query,omitempty"`
+	Headers map[string]*ValueMatcher `yaml:"headers" json:"headers,omitempty"`
+	Body    *ValueMatcher            `yaml:"body" json:"body,omitempty"`
+}
+
+// ValueMatcher matches a query parameter, header or body by equality, a
+// regular expression, a substring or presence.
+type ValueMatcher struct {
+	Equals   *string `yaml:"equals" json:"equals,omitempty"`
+	Matches  string  `yaml:"matches" json:"matches,omitempty"`
+	Contains string  `yaml:"contains" json:"contains,omitempty"`
+	Present  *bool   `yaml:"present" json:"present,omitempty"`
+}
+
+// Duration is a time.Duration written as a string, e.g. 150ms.
+type Duration time.Duration
+
+// ParseDuration parses a duration string, e.g. 150ms.
+func ParseDuration(s string) (Duration, error) {
+	d, err := time.ParseDuration(s)
+	return Duration(d), err
+}
+
+// UnmarshalYAML decodes a duration string.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// MarshalJSON encodes a duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
 }
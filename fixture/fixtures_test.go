@@ -0,0 +1,38 @@
+package fixture
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestPathItemMethods(t *testing.T) {
+	var spec Spec
+	err := yaml.Unmarshal([]byte(`
+services:
+  yfin:
+    paths:
+      "/v7/finance/quote":
+        get:
+          resource: quote
+        delete:
+          resource: quote
+      "/v8/finance/chart":
+        resource: chart
+`), &spec)
+	assert.NoError(t, err)
+
+	paths := spec.Services[ServiceYFin].Paths
+	quote := paths["/v7/finance/quote"]
+	assert.Len(t, quote, 2)
+	assert.Equal(t, YFinQuotes, quote["DELETE"].ResourceID)
+	assert.Equal(t, quote["GET"], quote.Operation("HEAD"))
+	assert.Nil(t, quote.Operation("POST"))
+	assert.Equal(t, []string{"GET", "HEAD", "DELETE", "OPTIONS"}, quote.Allow())
+
+	// Paths written as one operation take GET.
+	chart := paths["/v8/finance/chart"]
+	assert.Equal(t, YFinChart, chart["GET"].ResourceID)
+	assert.Equal(t, []string{"GET", "HEAD", "OPTIONS"}, chart.Allow())
+}
@@ -0,0 +1,43 @@
+package fixture
+
+// HAR is an HTTP archive of captured traffic, as saved by browsers.
+type HAR struct {
+	Log *HARLog `json:"log"`
+}
+
+// HARLog lists the captured exchanges.
+type HARLog struct {
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HAREntry is one captured request and its response.
+type HAREntry struct {
+	Request  *HARRequest  `json:"request"`
+	Response *HARResponse `json:"response"`
+}
+
+// HARRequest is a captured request.
+type HARRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// HARResponse is a captured response.
+type HARResponse struct {
+	Status  int          `json:"status"`
+	Headers []*HARHeader `json:"headers"`
+	Content *HARContent  `json:"content"`
+}
+
+// HARHeader is a captured header.
+type HARHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARContent is a captured response body, base64 encoded if binary.
+type HARContent struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding"`
+}
@@ -0,0 +1,217 @@
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/piquette/finance-mock/utils"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// fixtureExts are the extensions of fixture files in a directory tree.
+var fixtureExts = []string{".json", ".yaml", ".yml"}
+
+// treeLoader merges fixture files into one tree, remembering which file set
+// each key.
+type treeLoader struct {
+	tree   map[string]interface{}
+	owners map[string]string
+	errs   []string
+}
+
+// LoadDir loads fixtures from a directory laid out as
+// service/resource/SYMBOL[/STATE].json, or .yaml. Directories and the file
+// name make up the keys of the file's content, and path segments are
+// unescaped, e.g. EUR%2FUSD. Files are deep merged, and keys set by more than
+// one file are reported with the files setting them.
+func LoadDir(dir string) (*Fixtures, error) {
+	l := &treeLoader{tree: make(map[string]interface{}), owners: make(map[string]string)}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if !utils.Contains(fixtureExts, ext) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, strings.TrimSuffix(path, ext))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		for _, segment := range strings.Split(filepath.ToSlash(rel), "/") {
+			key, err := url.PathUnescape(segment)
+			if err != nil {
+				return fmt.Errorf("%v: %v", path, err)
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) < 2 {
+			return fmt.Errorf("%v: fixture files go below a service and a resource directory", path)
+		}
+
+		v, err := readFixtureFile(path, ext)
+		if err != nil {
+			return err
+		}
+		l.set(keys, v, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(l.errs) > 0 {
+		sort.Strings(l.errs)
+		return nil, fmt.Errorf("%v", strings.Join(l.errs, "\n"))
+	}
+	return FromTree(l.tree), nil
+}
+
+// readFixtureFile decodes a json or yaml file into a json tree.
+func readFixtureFile(path, ext string) (interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if ext == ".json" {
+		err = json.Unmarshal(data, &v)
+	} else {
+		err = yaml.Unmarshal(data, &v)
+		if err == nil {
+			// Decode yaml numbers the way json does.
+			data, err = json.Marshal(utils.NormalizeYAML(v))
+			if err == nil {
+				err = json.Unmarshal(data, &v)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return v, nil
+}
+
+// set merges a file's content into the tree at a key path.
+func (l *treeLoader) set(keys []string, v interface{}, file string) {
+	node := l.tree
+	for i, key := range keys[:len(keys)-1] {
+		owner := strings.Join(keys[:i+1], "/")
+		child, ok := node[key].(map[string]interface{})
+		if !ok {
+			if _, exists := node[key]; exists {
+				l.conflict(owner, file)
+				return
+			}
+			child = make(map[string]interface{})
+			node[key] = child
+			l.owners[owner] = file
+		}
+		node = child
+	}
+	l.merge(node, keys[len(keys)-1], strings.Join(keys, "/"), v, file)
+}
+
+func (l *treeLoader) merge(node map[string]interface{}, key, path string, v interface{}, file string) {
+	existing, exists := node[key]
+	if !exists {
+		node[key] = v
+		l.own(path, v, file)
+		return
+	}
+
+	dst, dstOK := existing.(map[string]interface{})
+	src, srcOK := v.(map[string]interface{})
+	if !dstOK || !srcOK {
+		if jsonEqual(existing, v) {
+			l.errs = append(l.errs, fmt.Sprintf("duplicate key %v in %v and %v", path, l.owners[path], file))
+			return
+		}
+		l.conflict(path, file)
+		return
+	}
+	for k, child := range src {
+		l.merge(dst, k, path+"/"+k, child, file)
+	}
+}
+
+// own records the file setting a node and every key below it.
+func (l *treeLoader) own(path string, v interface{}, file string) {
+	l.owners[path] = file
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, child := range m {
+			l.own(path+"/"+k, child, file)
+		}
+	}
+}
+
+func (l *treeLoader) conflict(path, file string) {
+	l.errs = append(l.errs, fmt.Sprintf("conflicting key %v in %v and %v", path, l.owners[path], file))
+}
+
+func jsonEqual(a, b interface{}) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(x) == string(y)
+}
+
+// FromTree turns a generic json tree of services and resources into
+// fixtures.
+func FromTree(tree map[string]interface{}) *Fixtures {
+	f := &Fixtures{Resources: make(map[ServiceID]Resources, len(tree))}
+	for service, v := range tree {
+		m, _ := v.(map[string]interface{})
+		resources := make(Resources, len(m))
+		for resource, v := range m {
+			resources[ResourceID(resource)] = v
+		}
+		f.Resources[ServiceID(service)] = resources
+	}
+	return f
+}
+
+// Merge deep merges other fixtures over these. Values in the others win.
+func (f *Fixtures) Merge(other *Fixtures) {
+	if f.Resources == nil {
+		f.Resources = make(map[ServiceID]Resources)
+	}
+	for service, resources := range other.Resources {
+		if f.Resources[service] == nil {
+			f.Resources[service] = make(Resources)
+		}
+		for resource, v := range resources {
+			f.Resources[service][resource] = mergeTree(f.Resources[service][resource], v)
+		}
+	}
+}
+
+// mergeTree deep merges src over dst, copying the maps it changes.
+func mergeTree(dst, src interface{}) interface{} {
+	d, dOK := dst.(map[string]interface{})
+	s, sOK := src.(map[string]interface{})
+	if !dOK || !sOK {
+		return src
+	}
+
+	merged := make(map[string]interface{}, len(d)+len(s))
+	for k, v := range d {
+		merged[k] = v
+	}
+	for k, v := range s {
+		merged[k] = mergeTree(merged[k], v)
+	}
+	return merged
+}
@@ -0,0 +1,92 @@
+package fixture
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// writeFiles writes files to a new temporary directory, which the caller
+// removes.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "finance-mock")
+	assert.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"yfin/quote/AAPL/REGULAR.json": `{"symbol": "AAPL", "regularMarketPrice": 1.5}`,
+		"yfin/quote/AAPL/PRE.yaml":     "symbol: AAPL\nregularMarketPrice: 1\n",
+		"yfin/quote/EUR%2FUSD.json":    `{"POST": {"symbol": "EUR/USD"}}`,
+		"yfin/chart/SPY.yml":           "meta: {symbol: SPY}\n",
+		"yfin/chart/README.md":         "not a fixture",
+		"yfin/options.json":            `{"SPY": {"chain": {}}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	f, err := LoadDir(dir)
+	assert.NoError(t, err)
+
+	quotes := f.Resources[ServiceYFin][YFinQuotes].(map[string]interface{})
+	aapl := quotes["AAPL"].(map[string]interface{})
+	assert.Equal(t, 1.5, aapl["REGULAR"].(map[string]interface{})["regularMarketPrice"])
+	assert.Equal(t, 1.0, aapl["PRE"].(map[string]interface{})["regularMarketPrice"])
+	assert.Contains(t, quotes, "EUR/USD")
+
+	chart := f.Resources[ServiceYFin][YFinChart].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"meta": map[string]interface{}{"symbol": "SPY"}}, chart["SPY"])
+	assert.Contains(t, f.Resources[ServiceYFin][YFinOptions], "SPY")
+}
+
+func TestLoadDirConflicts(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"yfin/quote/AAPL.json":         `{"REGULAR": {"symbol": "AAPL", "regularMarketPrice": 1.5}}`,
+		"yfin/quote/AAPL/REGULAR.yaml": "symbol: AAPL\nregularMarketPrice: 2\n",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := LoadDir(dir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate key yfin/quote/AAPL/REGULAR/symbol")
+	assert.Contains(t, err.Error(), "conflicting key yfin/quote/AAPL/REGULAR/regularMarketPrice")
+	assert.Contains(t, err.Error(), filepath.Join(dir, "yfin", "quote", "AAPL.json"))
+	assert.Contains(t, err.Error(), filepath.Join(dir, "yfin", "quote", "AAPL", "REGULAR.yaml"))
+
+	dir = writeFiles(t, map[string]string{"yfin.json": `{}`})
+	defer os.RemoveAll(dir)
+	_, err = LoadDir(dir)
+	assert.Error(t, err)
+
+	dir = writeFiles(t, map[string]string{"yfin/quote/AAPL.json": `{`})
+	defer os.RemoveAll(dir)
+	_, err = LoadDir(dir)
+	assert.Error(t, err)
+}
+
+func TestFixturesMerge(t *testing.T) {
+	f := &Fixtures{Resources: map[ServiceID]Resources{
+		ServiceYFin: {YFinQuotes: map[string]interface{}{
+			"AAPL": map[string]interface{}{"REGULAR": 1, "PRE": 2},
+		}},
+	}}
+	f.Merge(&Fixtures{Resources: map[ServiceID]Resources{
+		ServiceYFin: {
+			YFinQuotes: map[string]interface{}{"AAPL": map[string]interface{}{"PRE": 3}},
+			YFinChart:  map[string]interface{}{"SPY": map[string]interface{}{}},
+		},
+	}})
+
+	assert.Equal(t, map[string]interface{}{
+		"AAPL": map[string]interface{}{"REGULAR": 1, "PRE": 3},
+	}, f.Resources[ServiceYFin][YFinQuotes])
+	assert.Contains(t, f.Resources[ServiceYFin][YFinChart], "SPY")
+}
@@ -0,0 +1,376 @@
+package fixture
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/piquette/finance-mock/utils"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// OpenAPI is the part of an OpenAPI 3 document mapped onto a spec. The
+// x-finance-mock-service extension names the service its paths belong to,
+// yfin by default, and can be given per path too.
+type OpenAPI struct {
+	OpenAPI    string                      `yaml:"openapi"`
+	Service    ServiceID                   `yaml:"x-finance-mock-service"`
+	Paths      map[string]*OpenAPIPathItem `yaml:"paths"`
+	Components *OpenAPIComponents          `yaml:"components"`
+}
+
+// OpenAPIPathItem is the operations of a path and the parameters they share.
+// The x-finance-mock-service extension overrides the document's service, and
+// x-finance-mock-prefix routes the paths extending it to its operations.
+type OpenAPIPathItem struct {
+	Service    ServiceID           `yaml:"x-finance-mock-service"`
+	Prefix     bool                `yaml:"x-finance-mock-prefix"`
+	Parameters []*OpenAPIParameter `yaml:"parameters"`
+	Get        *OpenAPIOperation   `yaml:"get"`
+	Head       *OpenAPIOperation   `yaml:"head"`
+	Post       *OpenAPIOperation   `yaml:"post"`
+	Put        *OpenAPIOperation   `yaml:"put"`
+	Patch      *OpenAPIOperation   `yaml:"patch"`
+	Delete     *OpenAPIOperation   `yaml:"delete"`
+	Options    *OpenAPIOperation   `yaml:"options"`
+	Trace      *OpenAPIOperation   `yaml:"trace"`
+}
+
+// OpenAPIOperation is an operation. The x-finance-mock-resource extension
+// names the fixture resource serving it.
+type OpenAPIOperation struct {
+	Parameters []*OpenAPIParameter         `yaml:"parameters"`
+	Responses  map[string]*OpenAPIResponse `yaml:"responses"`
+	Resource   ResourceID                  `yaml:"x-finance-mock-resource"`
+}
+
+// OpenAPIParameter is a parameter, or a reference to one.
+type OpenAPIParameter struct {
+	Ref         string         `yaml:"$ref"`
+	Name        string         `yaml:"name"`
+	In          string         `yaml:"in"`
+	Description string         `yaml:"description"`
+	Required    bool           `yaml:"required"`
+	Schema      *OpenAPISchema `yaml:"schema"`
+}
+
+// OpenAPISchema is the part of a schema constraining parameter values.
+type OpenAPISchema struct {
+	Ref       string         `yaml:"$ref"`
+	Type      string         `yaml:"type"`
+	Format    string         `yaml:"format"`
+	Enum      []interface{}  `yaml:"enum"`
+	Default   interface{}    `yaml:"default"`
+	Pattern   string         `yaml:"pattern"`
+	Minimum   *float64       `yaml:"minimum"`
+	Maximum   *float64       `yaml:"maximum"`
+	MinLength *float64       `yaml:"minLength"`
+	MaxLength *float64       `yaml:"maxLength"`
+	MinItems  *float64       `yaml:"minItems"`
+	MaxItems  *float64       `yaml:"maxItems"`
+	Items     *OpenAPISchema `yaml:"items"`
+}
+
+// OpenAPIResponse is a response, or a reference to one.
+type OpenAPIResponse struct {
+	Ref     string                       `yaml:"$ref"`
+	Content map[string]*OpenAPIMediaType `yaml:"content"`
+}
+
+// OpenAPIMediaType holds the examples of a response body.
+type OpenAPIMediaType struct {
+	Example  interface{}                `yaml:"example"`
+	Examples map[string]*OpenAPIExample `yaml:"examples"`
+}
+
+// OpenAPIExample is a named example, or a reference to one.
+type OpenAPIExample struct {
+	Ref   string      `yaml:"$ref"`
+	Value interface{} `yaml:"value"`
+}
+
+// OpenAPIComponents holds the objects references point at.
+type OpenAPIComponents struct {
+	Parameters map[string]*OpenAPIParameter `yaml:"parameters"`
+	Schemas    map[string]*OpenAPISchema    `yaml:"schemas"`
+	Responses  map[string]*OpenAPIResponse  `yaml:"responses"`
+	Examples   map[string]*OpenAPIExample   `yaml:"examples"`
+}
+
+// IsOpenAPI reports whether a yaml or json document is an OpenAPI document.
+func IsOpenAPI(data []byte) bool {
+	var doc struct {
+		OpenAPI string `yaml:"openapi"`
+	}
+	return yaml.Unmarshal(data, &doc) == nil && doc.OpenAPI != ""
+}
+
+// ParseOpenAPI maps an OpenAPI 3 document, in yaml or json, onto a spec. Query
+// parameters and their schemas become parameters, and the first example of
+// the first successful response is served by operations without a resource.
+func ParseOpenAPI(data []byte) (*Spec, error) {
+	var doc OpenAPI
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version: %v", doc.OpenAPI)
+	}
+	if doc.Components == nil {
+		doc.Components = &OpenAPIComponents{}
+	}
+	return doc.spec()
+}
+
+func (doc *OpenAPI) spec() (*Spec, error) {
+	defaultService := doc.Service
+	if defaultService == "" {
+		defaultService = ServiceYFin
+	}
+
+	services := make(map[ServiceID]*Service)
+	for path, item := range doc.Paths {
+		if item == nil {
+			continue
+		}
+		service := item.Service
+		if service == "" {
+			service = defaultService
+		}
+		if services[service] == nil {
+			services[service] = &Service{Paths: make(map[Path]PathItem)}
+		}
+
+		pathItem := make(PathItem)
+		for method, op := range item.operations() {
+			operation, err := doc.operation(item, op)
+			if err != nil {
+				return nil, fmt.Errorf("%v %v: %v", method, path, err)
+			}
+			pathItem[method] = operation
+		}
+		services[service].Paths[Path(path)] = pathItem
+	}
+
+	return &Spec{Services: services}, nil
+}
+
+// operations lists the operations of a path item by method.
+func (item *OpenAPIPathItem) operations() map[string]*OpenAPIOperation {
+	ops := make(map[string]*OpenAPIOperation)
+	for method, op := range map[string]*OpenAPIOperation{
+		"GET": item.Get, "HEAD": item.Head, "POST": item.Post, "PUT": item.Put,
+		"PATCH": item.Patch, "DELETE": item.Delete, "OPTIONS": item.Options, "TRACE": item.Trace,
+	} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+func (doc *OpenAPI) operation(item *OpenAPIPathItem, op *OpenAPIOperation) (*Operation, error) {
+	operation := &Operation{ResourceID: op.Resource}
+	if item.Prefix {
+		operation.Prefix = &item.Prefix
+	}
+
+	// Operation parameters override those of the path.
+	var params []*OpenAPIParameter
+	seen := make(map[string]bool)
+	for _, list := range [][]*OpenAPIParameter{op.Parameters, item.Parameters} {
+		for _, p := range list {
+			p, err := doc.parameter(p)
+			if err != nil {
+				return nil, err
+			}
+			if p.In != "query" || seen[p.Name] {
+				continue
+			}
+			seen[p.Name] = true
+			params = append(params, p)
+		}
+	}
+	for _, p := range params {
+		param, err := doc.queryParameter(p)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %v: %v", p.Name, err)
+		}
+		operation.Parameters = append(operation.Parameters, param)
+	}
+
+	if operation.ResourceID == "" {
+		example, err := doc.example(op)
+		if err != nil {
+			return nil, err
+		}
+		operation.Example = example
+	}
+	return operation, nil
+}
+
+func (doc *OpenAPI) parameter(p *OpenAPIParameter) (*OpenAPIParameter, error) {
+	for i := 0; p != nil && p.Ref != ""; i++ {
+		name := strings.TrimPrefix(p.Ref, "#/components/parameters/")
+		if name == p.Ref || i > 10 {
+			return nil, fmt.Errorf("unsupported reference: %v", p.Ref)
+		}
+		p = doc.Components.Parameters[name]
+	}
+	if p == nil {
+		return nil, fmt.Errorf("missing parameter")
+	}
+	return p, nil
+}
+
+func (doc *OpenAPI) schema(s *OpenAPISchema) (*OpenAPISchema, error) {
+	for i := 0; s != nil && s.Ref != ""; i++ {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if name == s.Ref || i > 10 {
+			return nil, fmt.Errorf("unsupported reference: %v", s.Ref)
+		}
+		s = doc.Components.Schemas[name]
+	}
+	if s == nil {
+		return &OpenAPISchema{}, nil
+	}
+	return s, nil
+}
+
+// queryParameter maps a query parameter and its schema. Integers formatted
+// as unix-time are epochs and arrays are comma separated lists constrained
+// by their items.
+func (doc *OpenAPI) queryParameter(p *OpenAPIParameter) (*Parameter, error) {
+	s, err := doc.schema(p.Schema)
+	if err != nil {
+		return nil, err
+	}
+	param := &Parameter{
+		Name:        p.Name,
+		Description: p.Description,
+		Required:    p.Required,
+		Default:     openAPIValue(s.Default),
+	}
+
+	values := s
+	switch s.Type {
+	case "integer":
+		param.Type = ParamInt
+		if s.Format == "unix-time" || s.Format == "epoch" {
+			param.Type = ParamEpoch
+		}
+		param.Min, param.Max = s.Minimum, s.Maximum
+	case "boolean":
+		param.Type = ParamBool
+	case "array":
+		param.Type = ParamList
+		param.Min, param.Max = s.MinItems, s.MaxItems
+		values, err = doc.schema(s.Items)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		param.Type = ParamString
+		param.Min, param.Max = s.MinLength, s.MaxLength
+	}
+
+	param.Pattern = values.Pattern
+	for _, v := range values.Enum {
+		param.Enum = append(param.Enum, openAPIValue(v))
+	}
+	return param, nil
+}
+
+// openAPIValue writes a schema value the way it's given in a query.
+func openAPIValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		values := make([]string, len(t))
+		for i, item := range t {
+			values[i] = openAPIValue(item)
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// example finds the first example of the first successful json response.
+func (doc *OpenAPI) example(op *OpenAPIOperation) (*Step, error) {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		status, err := strconv.Atoi(strings.Replace(code, "XX", "00", 1))
+		if err != nil || status < 200 || status > 299 {
+			continue
+		}
+
+		resp := op.Responses[code]
+		for i := 0; resp != nil && resp.Ref != ""; i++ {
+			name := strings.TrimPrefix(resp.Ref, "#/components/responses/")
+			if name == resp.Ref || i > 10 {
+				return nil, fmt.Errorf("unsupported reference: %v", resp.Ref)
+			}
+			resp = doc.Components.Responses[name]
+		}
+		if resp == nil {
+			continue
+		}
+
+		for _, contentType := range sortedMediaTypes(resp.Content) {
+			media := resp.Content[contentType]
+			if media == nil {
+				continue
+			}
+			if media.Example != nil {
+				return &Step{Status: status, Body: utils.NormalizeYAML(media.Example)}, nil
+			}
+			for _, name := range sortedExamples(media.Examples) {
+				example := media.Examples[name]
+				for i := 0; example != nil && example.Ref != ""; i++ {
+					ref := strings.TrimPrefix(example.Ref, "#/components/examples/")
+					if ref == example.Ref || i > 10 {
+						return nil, fmt.Errorf("unsupported reference: %v", example.Ref)
+					}
+					example = doc.Components.Examples[ref]
+				}
+				if example != nil && example.Value != nil {
+					return &Step{Status: status, Body: utils.NormalizeYAML(example.Value)}, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// sortedMediaTypes lists json media types first.
+func sortedMediaTypes(content map[string]*OpenAPIMediaType) []string {
+	types := make([]string, 0, len(content))
+	for t := range content {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		iJSON, jJSON := strings.Contains(types[i], "json"), strings.Contains(types[j], "json")
+		if iJSON != jJSON {
+			return iJSON
+		}
+		return types[i] < types[j]
+	})
+	return types
+}
+
+func sortedExamples(examples map[string]*OpenAPIExample) []string {
+	names := make([]string, 0, len(examples))
+	for name := range examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,114 @@
+package fixture
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+const testOpenAPI = `
+openapi: 3.0.3
+info: {title: Yahoo Finance, version: "1"}
+paths:
+  /v7/finance/quote:
+    parameters:
+    - $ref: '#/components/parameters/Symbols'
+    get:
+      x-finance-mock-resource: quote
+      parameters:
+      - {name: X-Api-Key, in: header}
+      - name: fields
+        in: query
+        schema: {type: string, maxLength: 100}
+      responses:
+        "200": {description: ok}
+  /v8/finance/chart/{symbol}:
+    get:
+      x-finance-mock-resource: chart
+      parameters:
+      - {name: symbol, in: path, required: true, schema: {type: string}}
+      - name: period1
+        in: query
+        schema: {type: integer, format: unix-time, minimum: 0}
+      - name: interval
+        in: query
+        schema: {$ref: '#/components/schemas/Interval'}
+      - name: includePrePost
+        in: query
+        schema: {type: boolean, default: false}
+  /v1/finance/trending/{region}:
+    get:
+      responses:
+        "404": {description: not found}
+        "200":
+          $ref: '#/components/responses/Trending'
+components:
+  parameters:
+    Symbols:
+      name: symbols
+      in: query
+      required: true
+      description: Symbols to quote
+      schema:
+        type: array
+        minItems: 1
+        items: {type: string, pattern: '[A-Z^=.]+'}
+  schemas:
+    Interval: {type: string, enum: [1d, 1wk], default: 1d}
+  responses:
+    Trending:
+      content:
+        text/plain: {example: trending}
+        application/json:
+          examples:
+            us:
+              value: {finance: {result: [{count: 1, quotes: [{symbol: AAPL}]}]}}
+`
+
+func TestParseOpenAPI(t *testing.T) {
+	assert.True(t, IsOpenAPI([]byte(testOpenAPI)))
+	assert.False(t, IsOpenAPI([]byte("services: {}")))
+
+	spec, err := ParseOpenAPI([]byte(testOpenAPI))
+	assert.NoError(t, err)
+	paths := spec.Services[ServiceYFin].Paths
+	assert.Len(t, paths, 3)
+
+	quote := paths["/v7/finance/quote"]["GET"]
+	assert.Equal(t, YFinQuotes, quote.ResourceID)
+	assert.Nil(t, quote.Example)
+	one, hundred := 1.0, 100.0
+	assert.Equal(t, []*Parameter{
+		{Name: "fields", Type: ParamString, Max: &hundred},
+		{Name: "symbols", Description: "Symbols to quote", Required: true, Type: ParamList, Pattern: "[A-Z^=.]+", Min: &one},
+	}, quote.Parameters)
+
+	chart := paths["/v8/finance/chart/{symbol}"]["GET"]
+	zero := 0.0
+	assert.Equal(t, []*Parameter{
+		{Name: "period1", Type: ParamEpoch, Min: &zero},
+		{Name: "interval", Type: ParamString, Enum: []string{"1d", "1wk"}, Default: "1d"},
+		{Name: "includePrePost", Type: ParamBool, Default: "false"},
+	}, chart.Parameters)
+
+	// Operations without a resource serve their json example.
+	trending := paths["/v1/finance/trending/{region}"]["GET"]
+	assert.Equal(t, ResourceID(""), trending.ResourceID)
+	assert.Equal(t, 200, trending.Example.Status)
+	assert.Equal(t, map[string]interface{}{
+		"finance": map[string]interface{}{
+			"result": []interface{}{map[string]interface{}{
+				"count":  1,
+				"quotes": []interface{}{map[string]interface{}{"symbol": "AAPL"}},
+			}},
+		},
+	}, trending.Example.Body)
+}
+
+func TestParseOpenAPIErrors(t *testing.T) {
+	_, err := ParseOpenAPI([]byte(`{"openapi": "2.0", "paths": {}}`))
+	assert.EqualError(t, err, "unsupported openapi version: 2.0")
+
+	_, err = ParseOpenAPI([]byte(`{"openapi": "3.1.0", "paths": {"/a": {"get": {"parameters": [{"$ref": "other.yaml#/x"}]}}}}`))
+	assert.EqualError(t, err, "GET /a: unsupported reference: other.yaml#/x")
+}
@@ -10,7 +10,11 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/piquette/finance-mock/fixture"
 	"github.com/piquette/finance-mock/server"
@@ -33,11 +37,37 @@ func main() {
 	var port int
 	var fixturesPath string
 	var specPath string
+	var scenariosPath string
+	var stubsPath string
+	var sessionIdle time.Duration
+	var reloadInterval time.Duration
+	var static bool
+	var strict bool
+	var rebaseTime bool
+	var captureDate string
+	var record string
+	var recordFile string
+	var drift string
+	var harPath string
+	var harIgnore string
 	var unix string
 
 	flag.IntVar(&port, "port", defaultPort, "Port to listen on")
-	flag.StringVar(&fixturesPath, "fixtures", "", "Path to fixtures to use instead of bundled version")
-	flag.StringVar(&specPath, "spec", "", "Path to spec to use instead of bundled version")
+	flag.StringVar(&fixturesPath, "fixtures", "", "Comma separated fixture files or directories to use instead of bundled version, merged in order; the name bundled stands for the bundled fixtures")
+	flag.StringVar(&specPath, "spec", "", "Path to spec, or OpenAPI 3 document, to use instead of bundled version")
+	flag.StringVar(&scenariosPath, "scenarios", "", "Path to a file of scripted response scenarios")
+	flag.StringVar(&stubsPath, "stubs", "", "Path to a file of stubs matched before the fixtures")
+	flag.DurationVar(&reloadInterval, "reload-interval", time.Second, "How often to check -spec and -fixtures for changes, 0 to never reload")
+	flag.BoolVar(&static, "static", false, "Serve fixture templates as they are instead of evaluating them")
+	flag.BoolVar(&strict, "strict", false, "Reject query parameters the spec doesn't list for an endpoint")
+	flag.BoolVar(&rebaseTime, "rebase-time", false, "Shift fixture times from their capture date onto the latest trading day")
+	flag.StringVar(&captureDate, "capture-date", "", "Capture date of the fixtures for -rebase-time, YYYY-MM-DD (default the date of the latest quote)")
+	flag.DurationVar(&sessionIdle, "session-idle", server.DefaultSessionIdle, "How long sessions are kept without requests")
+	flag.StringVar(&record, "record", "", "Upstream base url to record the fixtures missing from")
+	flag.StringVar(&recordFile, "record-file", server.DefaultRecordFile, "Path to write recorded fixtures to")
+	flag.StringVar(&drift, "drift", "", "Upstream base url to compare the structure of fixture responses with")
+	flag.StringVar(&harPath, "har", "", "Path to a HAR file whose entries are served before the fixtures")
+	flag.StringVar(&harIgnore, "har-ignore", strings.Join(server.DefaultHARIgnore, ","), "Query parameters ignored when matching HAR entries")
 	flag.StringVar(&unix, "unix", "", "Unix socket to listen on")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose mode")
 	flag.BoolVar(&showVersion, "version", false, "Show version and exit")
@@ -65,8 +95,43 @@ func main() {
 		abort(err.Error())
 	}
 
+	// Get scenarios and stubs.
+	scenarios, err := getSpecFile(scenariosPath)
+	if err != nil {
+		abort(err.Error())
+	}
+	stubs, err := getSpecFile(stubsPath)
+	if err != nil {
+		abort(err.Error())
+	}
+
+	// Get captured traffic.
+	har, err := getHAR(harPath)
+	if err != nil {
+		abort(err.Error())
+	}
+	harIgnoreList := []string{}
+	if harIgnore != "" {
+		harIgnoreList = strings.Split(harIgnore, ",")
+	}
+
 	// Stub server.
-	stub := server.StubServer{Fixtures: fixtures, Spec: spec}
+	stub := server.StubServer{
+		Fixtures:    fixtures,
+		Spec:        spec,
+		Scenarios:   scenarios.Scenarios,
+		Stubs:       stubs.Stubs,
+		SessionIdle: sessionIdle,
+		Static:      static,
+		Strict:      strict,
+		RebaseTime:  rebaseTime,
+		CaptureDate: captureDate,
+		Record:      record,
+		RecordFile:  recordFile,
+		Drift:       drift,
+		HAR:         har,
+		HARIgnore:   harIgnoreList,
+	}
 	server.Version = version
 	server.Verbose = verbose
 
@@ -76,6 +141,38 @@ func main() {
 		abort(fmt.Sprintf("Error initializing router: %v\n", err))
 	}
 
+	// Print the OpenAPI description of the routes.
+	if len(flag.Args()) == 1 && flag.Arg(0) == "openapi" {
+		data, err := json.MarshalIndent(stub.OpenAPI(), "", "  ")
+		if err != nil {
+			abort(fmt.Sprintf("Error encoding openapi: %v\n", err))
+		}
+		fmt.Printf("%s\n", data)
+		return
+	}
+
+	// Reload the spec and fixtures on change.
+	if paths := watchedPaths(specPath, fixturesPath); len(paths) > 0 && reloadInterval > 0 {
+		load := func() (*fixture.Spec, *fixture.Fixtures, error) {
+			spec, err := getSpec(specPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%v", strings.TrimSpace(err.Error()))
+			}
+			fixtures, err := getFixtures(fixturesPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%v", strings.TrimSpace(err.Error()))
+			}
+			return spec, fixtures, nil
+		}
+		go stub.Watch(paths, reloadInterval, load, nil)
+	}
+
+	// Write recorded fixtures on shutdown.
+	if record != "" {
+		fmt.Printf("Recording from %v into %v\n", record, recordFile)
+		go saveRecordingOnSignal(&stub)
+	}
+
 	// Set handler.
 	http.HandleFunc("/", stub.HandleRequest)
 	http.HandleFunc("/config/", stub.HandleConfigRequest)
@@ -92,10 +189,38 @@ func main() {
 }
 
 func abort(message string) {
-	fmt.Fprintf(os.Stderr, message)
+	fmt.Fprint(os.Stderr, message)
 	os.Exit(1)
 }
 
+func saveRecordingOnSignal(stub *server.StubServer) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	err := stub.SaveRecording()
+	if err != nil {
+		abort(fmt.Sprintf("Error saving recording: %v\n", err))
+	}
+	os.Exit(0)
+}
+
+// watchedPaths lists the on-disk spec and fixture sources.
+func watchedPaths(specPath, fixturesPath string) []string {
+	var paths []string
+	if specPath != "" {
+		paths = append(paths, specPath)
+	}
+	if fixturesPath != "" {
+		for _, source := range strings.Split(fixturesPath, ",") {
+			if source != bundledFixtures {
+				paths = append(paths, source)
+			}
+		}
+	}
+	return paths
+}
+
 func getListener(port int, unix string) (net.Listener, error) {
 	var err error
 	var listener net.Listener
@@ -133,6 +258,14 @@ func getSpec(specPath string) (*fixture.Spec, error) {
 		return nil, fmt.Errorf("error loading spec: %v\n", err)
 	}
 
+	if fixture.IsOpenAPI(data) {
+		spec, err := fixture.ParseOpenAPI(data)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding openapi spec: %v\n", err)
+		}
+		return spec, nil
+	}
+
 	var spec fixture.Spec
 
 	err = yaml.Unmarshal(data, &spec)
@@ -143,15 +276,48 @@ func getSpec(specPath string) (*fixture.Spec, error) {
 	return &spec, nil
 }
 
+// bundledFixtures names the bundled fixtures in a list of fixture sources.
+const bundledFixtures = "bundled"
+
+// getFixtures loads a comma separated list of fixture sources, each a json
+// file, a directory tree or the bundled fixtures. Later sources are merged
+// over earlier ones.
 func getFixtures(fixturesPath string) (*fixture.Fixtures, error) {
+	if fixturesPath == "" {
+		fixturesPath = bundledFixtures
+	}
+
+	fixtures := &fixture.Fixtures{}
+	for _, source := range strings.Split(fixturesPath, ",") {
+		f, err := getFixtureSource(source)
+		if err != nil {
+			return nil, err
+		}
+		fixtures.Merge(f)
+	}
+	return fixtures, nil
+}
+
+func getFixtureSource(source string) (*fixture.Fixtures, error) {
 	var data []byte
 	var err error
 
-	if fixturesPath == "" {
+	if source == bundledFixtures {
 		// Load resources from go-bindata.
 		data, err = Asset("fixture/resources.json")
 	} else {
-		data, err = ioutil.ReadFile(fixturesPath)
+		var info os.FileInfo
+		info, err = os.Stat(source)
+		if err == nil && info.IsDir() {
+			fixtures, err := fixture.LoadDir(source)
+			if err != nil {
+				return nil, fmt.Errorf("error loading fixtures from %v:\n%v\n", source, err)
+			}
+			return fixtures, nil
+		}
+		if err == nil {
+			data, err = ioutil.ReadFile(source)
+		}
 	}
 
 	if err != nil {
@@ -165,3 +331,42 @@ func getFixtures(fixturesPath string) (*fixture.Fixtures, error) {
 	}
 	return &fixtures, nil
 }
+
+// getSpecFile loads a file laid out like the spec, e.g. holding scenarios or
+// stubs.
+func getSpecFile(path string) (*fixture.Spec, error) {
+	var spec fixture.Spec
+	if path == "" {
+		return &spec, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %v: %v\n", path, err)
+	}
+
+	err = yaml.Unmarshal(data, &spec)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %v: %v\n", path, err)
+	}
+
+	return &spec, nil
+}
+
+func getHAR(path string) (*fixture.HAR, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading har: %v\n", err)
+	}
+
+	var har fixture.HAR
+	err = json.Unmarshal(data, &har)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding har: %v\n", err)
+	}
+	return &har, nil
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+var chaosModes = []string{
+	string(fixture.ChaosTruncate),
+	string(fixture.ChaosInvalidJSON),
+	string(fixture.ChaosContentLength),
+	string(fixture.ChaosReset),
+	string(fixture.ChaosHang),
+}
+
+// chaosMonkey breaks the transport of responses per route.
+type chaosMonkey struct {
+	mu     sync.Mutex
+	routes map[fixture.Path]fixture.Chaos
+}
+
+func newChaosMonkey() *chaosMonkey {
+	return &chaosMonkey{routes: make(map[fixture.Path]fixture.Chaos)}
+}
+
+// set configures the chaos mode of a route.
+func (c *chaosMonkey) set(path fixture.Path, config *fixture.Chaos) error {
+	if !utils.Contains(chaosModes, string(config.Mode)) {
+		return fmt.Errorf("unknown chaos mode: %v", config.Mode)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routes[path] = *config
+	return nil
+}
+
+// clear removes the chaos mode of a route.
+func (c *chaosMonkey) clear(path fixture.Path) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.routes, path)
+}
+
+// config lists the chaos mode of every configured route.
+func (c *chaosMonkey) config() map[fixture.Path]fixture.Chaos {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	config := make(map[fixture.Path]fixture.Chaos, len(c.routes))
+	for path, chaos := range c.routes {
+		config[path] = chaos
+	}
+	return config
+}
+
+// wrap hijacks the response writer of a route with chaos configured.
+func (c *chaosMonkey) wrap(w http.ResponseWriter, r *http.Request, path fixture.Path) http.ResponseWriter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chaos, ok := c.routes[path]
+	if !ok {
+		chaos, ok = c.routes[allRoutes]
+	}
+	if !ok {
+		return w
+	}
+	return &chaosWriter{ResponseWriter: w, ctx: r.Context(), mode: chaos.Mode}
+}
+
+// chaosWriter breaks the response written through it.
+type chaosWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	mode   fixture.ChaosMode
+	status int
+}
+
+// WriteHeader holds the status back until the body is known.
+func (c *chaosWriter) WriteHeader(status int) {
+	c.status = status
+}
+
+// Write sends the body the way the chaos mode says.
+func (c *chaosWriter) Write(data []byte) (int, error) {
+	utils.Log(Verbose, "Breaking response: chaos=%v", c.mode)
+
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	h := c.Header()
+
+	switch c.mode {
+	case fixture.ChaosReset:
+		return 0, c.reset()
+
+	case fixture.ChaosHang:
+		c.ResponseWriter.WriteHeader(c.status)
+		if f, ok := c.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		<-c.ctx.Done()
+		return 0, c.ctx.Err()
+
+	case fixture.ChaosTruncate:
+		// The server closes the connection when the handler writes less than
+		// the content length.
+		h.Set("Content-Length", strconv.Itoa(len(data)))
+		c.ResponseWriter.WriteHeader(c.status)
+		return c.ResponseWriter.Write(data[:len(data)/2])
+
+	case fixture.ChaosContentLength:
+		h.Set("Content-Length", strconv.Itoa(len(data)+len(data)/2+1))
+		c.ResponseWriter.WriteHeader(c.status)
+		return c.ResponseWriter.Write(data)
+
+	case fixture.ChaosInvalidJSON:
+		broken := append(append([]byte{}, data[:len(data)/2]...), []byte(`,"}{`)...)
+		h.Set("Content-Length", strconv.Itoa(len(broken)))
+		c.ResponseWriter.WriteHeader(c.status)
+		return c.ResponseWriter.Write(broken)
+	}
+
+	c.ResponseWriter.WriteHeader(c.status)
+	return c.ResponseWriter.Write(data)
+}
+
+// reset drops the connection with a tcp rst, or aborts the handler if the
+// connection can't be hijacked.
+func (c *chaosWriter) reset() error {
+	hj, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		// Discard unsent data and send a rst instead of a fin.
+		tcp.SetLinger(0)
+	}
+	return conn.Close()
+}
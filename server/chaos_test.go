@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestChaosModes(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleRequest))
+	defer ts.Close()
+
+	get := func() (*http.Response, []byte, error) {
+		resp, err := http.Get(ts.URL + "/v7/finance/quote?symbols=AAPL")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return resp, body, err
+	}
+
+	assert.NoError(t, s.routes().chaos.set("/v7/finance/quote", &fixture.Chaos{Mode: fixture.ChaosInvalidJSON}))
+	_, body, err := get()
+	assert.NoError(t, err)
+	var v interface{}
+	assert.Error(t, json.Unmarshal(body, &v))
+
+	for _, mode := range []fixture.ChaosMode{fixture.ChaosTruncate, fixture.ChaosContentLength} {
+		assert.NoError(t, s.routes().chaos.set("/v7/finance/quote", &fixture.Chaos{Mode: mode}))
+		resp, _, err := get()
+		assert.NotNil(t, resp, mode)
+		assert.Error(t, err, mode)
+	}
+
+	assert.NoError(t, s.routes().chaos.set("/v7/finance/quote", &fixture.Chaos{Mode: fixture.ChaosReset}))
+	_, _, err = get()
+	assert.Error(t, err)
+
+	// Headers arrive, the body never does.
+	assert.NoError(t, s.routes().chaos.set("/v7/finance/quote", &fixture.Chaos{Mode: fixture.ChaosHang}))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("GET", ts.URL+"/v7/finance/quote?symbols=AAPL", nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err = ioutil.ReadAll(resp.Body)
+	assert.Error(t, err)
+	resp.Body.Close()
+
+	s.routes().chaos.clear("/v7/finance/quote")
+	_, body, err = get()
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &v))
+}
+
+func TestChaosConfig(t *testing.T) {
+	s := newTestServer(t)
+
+	status, _ := doConfigRequest(t, s, "POST", "/config/chaos", url.Values{"path": {"/v8/finance/chart"}, "mode": {"reset"}})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, fixture.ChaosReset, s.routes().chaos.config()["/v8/finance/chart"].Mode)
+
+	status, _ = doConfigRequest(t, s, "POST", "/config/chaos", url.Values{"mode": {"gremlins"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/chaos?path=/v8/finance/chart", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, s.routes().chaos.config())
+}
@@ -1,9 +1,14 @@
 package server
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/piquette/finance-mock/fixture"
 	"github.com/piquette/finance-mock/utils"
 )
 
@@ -25,6 +30,56 @@ type MarketState string
 func (s *StubServer) HandleConfigRequest(w http.ResponseWriter, r *http.Request) {
 
 	start := time.Now()
+
+	configPath := strings.Trim(strings.TrimPrefix(r.URL.Path, "/config"), "/")
+	if configPath == "fixtures" || strings.HasPrefix(configPath, "fixtures/") {
+		s.handleFixtureConfig(w, r, start)
+		return
+	}
+	if configPath == "sessions" || strings.HasPrefix(configPath, "sessions/") {
+		s.handleSessionConfig(w, r, start, strings.TrimPrefix(strings.TrimPrefix(configPath, "sessions"), "/"))
+		return
+	}
+
+	switch configPath {
+	case "":
+		s.handleMarketConfig(w, r, start)
+	case "latency":
+		s.handleLatencyConfig(w, r, start)
+	case "faults":
+		s.handleFaultConfig(w, r, start)
+	case "ratelimit":
+		s.handleRateLimitConfig(w, r, start)
+	case "scenarios":
+		s.handleScenarioConfig(w, r, start)
+	case "chaos":
+		s.handleChaosConfig(w, r, start)
+	case "throttle":
+		s.handleThrottleConfig(w, r, start)
+	case "stubs":
+		s.handleStubConfig(w, r, start)
+	case "record":
+		s.handleRecordConfig(w, r, start)
+	case "har":
+		s.handleHARConfig(w, r, start)
+	case "drift":
+		s.handleDriftConfig(w, r, start)
+	case "reload":
+		s.handleReloadConfig(w, r, start)
+	case "requests":
+		s.handleJournalConfig(w, r, start)
+	case "requests/verify":
+		s.handleVerifyConfig(w, r, start)
+	case "openapi.json":
+		s.handleOpenAPIConfig(w, r, start)
+	default:
+		utils.Log(Verbose, "Couldn't find config for url: %v", r.URL.String())
+		s.writeResponse(w, r, start, http.StatusNotFound, nil)
+	}
+}
+
+// handleMarketConfig sets the market state.
+func (s *StubServer) handleMarketConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
 	validStates := []string{string(MarketStatePre), string(MarketStateRegular), string(MarketStatePost)}
 
 	newState := r.PostFormValue("state")
@@ -34,10 +89,722 @@ func (s *StubServer) HandleConfigRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Set market state.
-	utils.Log(Verbose, "Changed market state from %v to %v", Market, newState)
-	Market = MarketState(newState)
+	// Set market state of the session, or of everyone.
+	if sess := s.session(r); sess != nil {
+		utils.Log(Verbose, "Changed market state of session %v to %v", sess.id, newState)
+		sess.setMarketState(MarketState(newState))
+	} else {
+		utils.Log(Verbose, "Changed market state from %v to %v", Market, newState)
+		Market = MarketState(newState)
+	}
 
 	// Write response.
 	s.writeResponse(w, r, start, http.StatusOK, nil)
 }
+
+// handleLatencyConfig lists, sets or clears the latency of a route. Omitting
+// the path configures every route without latency of its own.
+func (s *StubServer) handleLatencyConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeResponse(w, r, start, http.StatusOK, s.routes().latency.config())
+		return
+	case http.MethodDelete:
+		path := fixture.Path(r.FormValue("path"))
+		utils.Log(Verbose, "Cleared latency for route: %v", path)
+		s.configure(func(rtg *routing) error {
+			rtg.latency.clear(path)
+			return nil
+		})
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+		return
+	case http.MethodPost:
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	path := fixture.Path(r.PostFormValue("path"))
+	if path != allRoutes && !s.hasPath(path) {
+		utils.Log(Verbose, "Couldn't find route for latency config: %v", path)
+		s.writeResponse(w, r, start, http.StatusBadRequest, nil)
+		return
+	}
+
+	config, err := parseLatencyForm(r)
+	if err == nil {
+		err = s.configure(func(rtg *routing) error {
+			return rtg.latency.set(path, config)
+		})
+	}
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse latency config: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Log(Verbose, "Set latency for route %v to %+v", path, *config)
+	s.writeResponse(w, r, start, http.StatusOK, nil)
+}
+
+func parseLatencyForm(r *http.Request) (*fixture.Latency, error) {
+	var err error
+	config := &fixture.Latency{
+		Distribution: fixture.LatencyDistribution(r.PostFormValue("distribution")),
+	}
+
+	if v := r.PostFormValue("delay"); v != "" {
+		if config.Delay, err = fixture.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := r.PostFormValue("jitter"); v != "" {
+		if config.Jitter, err = fixture.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := r.PostFormValue("seed"); v != "" {
+		if config.Seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// handleFaultConfig lists, sets or clears the faults of a route. Omitting the
+// path configures every route, omitting symbols configures every symbol.
+func (s *StubServer) handleFaultConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeResponse(w, r, start, http.StatusOK, s.routes().faults.config())
+		return
+	case http.MethodDelete:
+		path := fixture.Path(r.FormValue("path"))
+		symbols := splitList(r.FormValue("symbols"))
+		if len(symbols) == 0 {
+			symbols = []string{anySymbol}
+		}
+		s.configure(func(rtg *routing) error {
+			for _, symbol := range symbols {
+				rtg.faults.clear(path, symbol)
+			}
+			return nil
+		})
+		utils.Log(Verbose, "Cleared faults for route: %v", path)
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+		return
+	case http.MethodPost:
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	path := fixture.Path(r.PostFormValue("path"))
+	if path != allRoutes && !s.hasPath(path) {
+		utils.Log(Verbose, "Couldn't find route for fault config: %v", path)
+		s.writeResponse(w, r, start, http.StatusBadRequest, nil)
+		return
+	}
+
+	config, err := parseFaultForm(r)
+	if err == nil {
+		err = s.configure(func(rtg *routing) error {
+			return rtg.faults.set(path, config)
+		})
+	}
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse fault config: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Log(Verbose, "Set fault for route %v to %+v", path, *config)
+	s.writeResponse(w, r, start, http.StatusOK, nil)
+}
+
+func parseFaultForm(r *http.Request) (*fixture.Fault, error) {
+	var err error
+	config := &fixture.Fault{
+		Body:    fixture.FaultBody(r.PostFormValue("body")),
+		Symbols: splitList(r.PostFormValue("symbols")),
+	}
+
+	if config.Rate, err = strconv.ParseFloat(r.PostFormValue("rate"), 64); err != nil {
+		return nil, err
+	}
+	for _, v := range splitList(r.PostFormValue("statuses")) {
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		config.Statuses = append(config.Statuses, status)
+	}
+	if v := r.PostFormValue("seed"); v != "" {
+		if config.Seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// splitList splits a comma separated form value.
+func splitList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// handleRateLimitConfig lists the buckets of, sets or clears the rate limit
+// of a route. Omitting the path configures every route.
+func (s *StubServer) handleRateLimitConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeResponse(w, r, start, http.StatusOK, s.routes().limiter.state())
+		return
+	case http.MethodDelete:
+		path := fixture.Path(r.FormValue("path"))
+		utils.Log(Verbose, "Cleared rate limit for route: %v", path)
+		s.configure(func(rtg *routing) error {
+			rtg.limiter.clear(path)
+			return nil
+		})
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+		return
+	case http.MethodPost:
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	path := fixture.Path(r.PostFormValue("path"))
+	if path != allRoutes && !s.hasPath(path) {
+		utils.Log(Verbose, "Couldn't find route for rate limit config: %v", path)
+		s.writeResponse(w, r, start, http.StatusBadRequest, nil)
+		return
+	}
+
+	config, err := parseRateLimitForm(r)
+	if err == nil {
+		err = s.configure(func(rtg *routing) error {
+			return rtg.limiter.set(path, config)
+		})
+	}
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse rate limit config: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Log(Verbose, "Set rate limit for route %v to %+v", path, *config)
+	s.writeResponse(w, r, start, http.StatusOK, nil)
+}
+
+func parseRateLimitForm(r *http.Request) (*fixture.RateLimit, error) {
+	var err error
+	config := &fixture.RateLimit{Key: r.PostFormValue("key")}
+
+	if config.Rate, err = strconv.ParseFloat(r.PostFormValue("rate"), 64); err != nil {
+		return nil, err
+	}
+	if v := r.PostFormValue("burst"); v != "" {
+		if config.Burst, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// handleScenarioConfig lists, adds or removes scenarios. Scenarios are posted
+// as json, adding one with the name of another replaces and rewinds it.
+func (s *StubServer) handleScenarioConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeResponse(w, r, start, http.StatusOK, s.routes().scenarios.status())
+		return
+	case http.MethodDelete:
+		name := r.FormValue("name")
+		utils.Log(Verbose, "Removed scenario: %v", name)
+		s.configure(func(rtg *routing) error {
+			rtg.scenarios.remove(name)
+			return nil
+		})
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+		return
+	case http.MethodPost:
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	var scenario fixture.Scenario
+	err := json.NewDecoder(r.Body).Decode(&scenario)
+	if err == nil {
+		err = s.configure(func(rtg *routing) error {
+			return rtg.addScenario(&scenario)
+		})
+	}
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse scenario: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Log(Verbose, "Added scenario: %v", scenario.Name)
+	s.writeResponse(w, r, start, http.StatusOK, nil)
+}
+
+// handleChaosConfig lists, sets or clears the chaos mode of a route. Omitting
+// the path configures every route.
+func (s *StubServer) handleChaosConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeResponse(w, r, start, http.StatusOK, s.routes().chaos.config())
+		return
+	case http.MethodDelete:
+		path := fixture.Path(r.FormValue("path"))
+		utils.Log(Verbose, "Cleared chaos for route: %v", path)
+		s.configure(func(rtg *routing) error {
+			rtg.chaos.clear(path)
+			return nil
+		})
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+		return
+	case http.MethodPost:
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	path := fixture.Path(r.PostFormValue("path"))
+	if path != allRoutes && !s.hasPath(path) {
+		utils.Log(Verbose, "Couldn't find route for chaos config: %v", path)
+		s.writeResponse(w, r, start, http.StatusBadRequest, nil)
+		return
+	}
+
+	config := &fixture.Chaos{Mode: fixture.ChaosMode(r.PostFormValue("mode"))}
+	err := s.configure(func(rtg *routing) error {
+		return rtg.chaos.set(path, config)
+	})
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse chaos config: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Log(Verbose, "Set chaos for route %v to %v", path, config.Mode)
+	s.writeResponse(w, r, start, http.StatusOK, nil)
+}
+
+// handleThrottleConfig lists, sets or clears the throttle of a route. Omitting
+// the path configures every route.
+func (s *StubServer) handleThrottleConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeResponse(w, r, start, http.StatusOK, s.routes().throttle.config())
+		return
+	case http.MethodDelete:
+		path := fixture.Path(r.FormValue("path"))
+		utils.Log(Verbose, "Cleared throttle for route: %v", path)
+		s.configure(func(rtg *routing) error {
+			rtg.throttle.clear(path)
+			return nil
+		})
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+		return
+	case http.MethodPost:
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	path := fixture.Path(r.PostFormValue("path"))
+	if path != allRoutes && !s.hasPath(path) {
+		utils.Log(Verbose, "Couldn't find route for throttle config: %v", path)
+		s.writeResponse(w, r, start, http.StatusBadRequest, nil)
+		return
+	}
+
+	config, err := parseThrottleForm(r)
+	if err == nil {
+		err = s.configure(func(rtg *routing) error {
+			return rtg.throttle.set(path, config)
+		})
+	}
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse throttle config: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Log(Verbose, "Set throttle for route %v to %+v", path, *config)
+	s.writeResponse(w, r, start, http.StatusOK, nil)
+}
+
+func parseThrottleForm(r *http.Request) (*fixture.Throttle, error) {
+	var err error
+	config := &fixture.Throttle{}
+
+	if v := r.PostFormValue("rate"); v != "" {
+		if config.Rate, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := r.PostFormValue("chunk"); v != "" {
+		if config.ChunkSize, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := r.PostFormValue("flush"); v != "" {
+		if config.FlushInterval, err = fixture.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// handleFixtureConfig reads, creates, replaces, merge patches or deletes the
+// fixture node at /config/fixtures/service/resource/... Deleting the root
+// drops every override. Requests of a session only touch its fixtures.
+func (s *StubServer) handleFixtureConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	store := s.fixtureStore(s.session(r))
+
+	path, err := fixtureNodePath(r)
+	if err != nil {
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			s.writeResponse(w, r, start, http.StatusOK, store.current())
+		case http.MethodDelete:
+			utils.Log(Verbose, "Reset fixtures")
+			store.reset()
+			if store == s.store {
+				s.replayRecording()
+			}
+			s.writeResponse(w, r, start, http.StatusOK, nil)
+		default:
+			s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		}
+		return
+	}
+
+	var existed bool
+	switch r.Method {
+	case http.MethodGet:
+		node, err := store.get(path)
+		if err != nil {
+			s.writeResponse(w, r, start, http.StatusNotFound, nil)
+			return
+		}
+		s.writeResponse(w, r, start, http.StatusOK, node)
+		return
+
+	case http.MethodPut, http.MethodPost, http.MethodPatch:
+		var body interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body == nil {
+			s.writeResponse(w, r, start, http.StatusBadRequest, "fixture node must not be null")
+			return
+		}
+
+		method := r.Method
+		existed, err = store.update(path, func(node interface{}, exists bool) (interface{}, error) {
+			switch {
+			case method == http.MethodPost && exists:
+				return nil, errNodeExists
+			case method == http.MethodPatch && !exists:
+				return nil, errNodeNotFound
+			case method == http.MethodPatch:
+				return utils.MergePatch(node, body), nil
+			}
+			return body, nil
+		})
+
+	case http.MethodDelete:
+		existed, err = store.update(path, func(node interface{}, exists bool) (interface{}, error) {
+			if !exists {
+				return nil, errNodeNotFound
+			}
+			return nil, nil
+		})
+
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	switch {
+	case err == errNodeNotFound:
+		s.writeResponse(w, r, start, http.StatusNotFound, nil)
+	case err == errNodeExists:
+		s.writeResponse(w, r, start, http.StatusConflict, nil)
+	case err != nil:
+		utils.Log(Verbose, "Rejected fixture write: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+	case !existed && r.Method != http.MethodDelete:
+		utils.Log(Verbose, "Created fixture node: %v", strings.Join(path, "/"))
+		s.writeResponse(w, r, start, http.StatusCreated, nil)
+	default:
+		utils.Log(Verbose, "Wrote fixture node: %v", strings.Join(path, "/"))
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+	}
+}
+
+// fixtureNodePath splits the fixture node path of a config url. Keys are
+// unescaped one by one so they may contain slashes.
+func fixtureNodePath(r *http.Request) ([]string, error) {
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/config/fixtures")
+
+	path := []string{}
+	for _, key := range strings.Split(rest, "/") {
+		if key == "" {
+			continue
+		}
+		k, err := url.PathUnescape(key)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, k)
+	}
+	return path, nil
+}
+
+// handleSessionConfig lists sessions, shows one with its journaled requests
+// or drops one.
+func (s *StubServer) handleSessionConfig(w http.ResponseWriter, r *http.Request, start time.Time, id string) {
+
+	if id == "" {
+		if r.Method != http.MethodGet {
+			s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		s.writeResponse(w, r, start, http.StatusOK, s.sessions.list())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sess := s.sessions.find(id)
+		if sess == nil {
+			s.writeResponse(w, r, start, http.StatusNotFound, nil)
+			return
+		}
+		info := sess.info()
+		info.Requests = s.journal.find(&requestMatcher{Session: id})
+		s.writeResponse(w, r, start, http.StatusOK, info)
+	case http.MethodDelete:
+		if !s.sessions.remove(id) {
+			s.writeResponse(w, r, start, http.StatusNotFound, nil)
+			return
+		}
+		utils.Log(Verbose, "Removed session: %v", id)
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+// handleJournalConfig lists the journaled requests matching the query
+// parameters, or clears the journal.
+func (s *StubServer) handleJournalConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+		matcher, err := parseRequestMatcher(r)
+		if err != nil {
+			s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.writeResponse(w, r, start, http.StatusOK, s.journal.find(matcher))
+	case http.MethodDelete:
+		utils.Log(Verbose, "Cleared request journal")
+		s.journal.reset()
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+// handleVerifyConfig checks the number of journaled requests matching a
+// posted json matcher. Failures answer 417 with the nearest misses.
+func (s *StubServer) handleVerifyConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	if r.Method != http.MethodPost {
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	var v verification
+	err := json.NewDecoder(r.Body).Decode(&v)
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse verification: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := s.journal.verify(&v)
+	if !result.OK {
+		utils.Log(Verbose, "Verification failed: expected %v, got %v", result.Expected, result.Count)
+		s.writeResponse(w, r, start, http.StatusExpectationFailed, result)
+		return
+	}
+	s.writeResponse(w, r, start, http.StatusOK, result)
+}
+
+// handleStubConfig lists, adds or removes stubs. Stubs are posted as json,
+// adding one with the name of another replaces it.
+func (s *StubServer) handleStubConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeResponse(w, r, start, http.StatusOK, s.routes().stubs.list())
+		return
+	case http.MethodDelete:
+		name := r.FormValue("name")
+		utils.Log(Verbose, "Removed stub: %v", name)
+		s.configure(func(rtg *routing) error {
+			rtg.stubs.remove(name)
+			return nil
+		})
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+		return
+	case http.MethodPost:
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	var stub fixture.Stub
+	err := json.NewDecoder(r.Body).Decode(&stub)
+	if err == nil {
+		err = s.configure(func(rtg *routing) error {
+			// Unnamed stubs keep the name they first got on reload.
+			name, err := rtg.stubs.add(&stub)
+			stub.Name = name
+			return err
+		})
+	}
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse stub: %v", err)
+		s.writeResponse(w, r, start, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Log(Verbose, "Added stub: %v", stub.Name)
+	s.writeResponse(w, r, start, http.StatusOK, map[string]string{"name": stub.Name})
+}
+
+// handleRecordConfig reports the recording, and writes it to the record file
+// when posted to.
+func (s *StubServer) handleRecordConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+	if s.recorder == nil {
+		s.writeResponse(w, r, start, http.StatusNotFound, "not recording")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		err := s.SaveRecording()
+		if err != nil {
+			utils.Log(Verbose, "Couldn't save recording: %v", err)
+			s.writeResponse(w, r, start, http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	s.writeResponse(w, r, start, http.StatusOK, s.recorder.info())
+}
+
+// handleHARConfig reports the HAR replay, or sets the ignored query
+// parameters when posted to.
+func (s *StubServer) handleHARConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+	if s.har == nil {
+		s.writeResponse(w, r, start, http.StatusNotFound, "not replaying a har")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		ignore := splitList(r.PostFormValue("ignore"))
+		utils.Log(Verbose, "Ignoring har query parameters: %v", ignore)
+		s.har.setIgnore(ignore)
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	s.writeResponse(w, r, start, http.StatusOK, s.har.info())
+}
+
+// handleDriftConfig lists or clears the drift reports. With drifted=true only
+// the reports with changes are listed.
+func (s *StubServer) handleDriftConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+	if s.drift == nil {
+		s.writeResponse(w, r, start, http.StatusNotFound, "not checking drift")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeResponse(w, r, start, http.StatusOK, s.drift.list(r.FormValue("drifted") == "true"))
+	case http.MethodDelete:
+		utils.Log(Verbose, "Cleared drift reports")
+		s.drift.reset()
+		s.writeResponse(w, r, start, http.StatusOK, nil)
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+// handleReloadConfig reports the reloads of the spec and fixtures, or
+// reloads them when posted to.
+func (s *StubServer) handleReloadConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		err := s.reloadNow()
+		if err == errNotWatching {
+			s.writeResponse(w, r, start, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			s.writeResponse(w, r, start, http.StatusUnprocessableEntity, s.reload.info())
+			return
+		}
+	default:
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	s.writeResponse(w, r, start, http.StatusOK, s.reload.info())
+}
+
+// handleOpenAPIConfig describes the routes as an OpenAPI document.
+func (s *StubServer) handleOpenAPIConfig(w http.ResponseWriter, r *http.Request, start time.Time) {
+	if r.Method != http.MethodGet {
+		s.writeResponse(w, r, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	s.writeResponse(w, r, start, http.StatusOK, s.OpenAPI())
+}
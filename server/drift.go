@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+const (
+	// DriftAdded is a key the upstream has and the fixtures don't.
+	DriftAdded = "added"
+
+	// DriftRemoved is a key the fixtures have and the upstream doesn't.
+	DriftRemoved = "removed"
+
+	// DriftType is a key whose value has another type upstream.
+	DriftType = "type"
+)
+
+// driftDetector sends the requests served from fixtures to an upstream as
+// well, and compares the structure of both responses. Values are ignored.
+type driftDetector struct {
+	mu       sync.Mutex
+	upstream *upstream
+	reports  map[string]*driftReport
+	pending  sync.WaitGroup
+}
+
+// driftReport is the latest comparison of a request.
+type driftReport struct {
+	Request string         `json:"request"`
+	Route   fixture.Path   `json:"route"`
+	Time    time.Time      `json:"time"`
+	Checks  int            `json:"checks"`
+	Status  int            `json:"status,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Changes []*driftChange `json:"changes"`
+}
+
+// driftChange is a structural difference at a key. Array elements are
+// keyed with [].
+type driftChange struct {
+	Kind     string `json:"kind"`
+	Key      string `json:"key"`
+	Fixture  string `json:"fixture,omitempty"`
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// String describes the change.
+func (c *driftChange) String() string {
+	switch c.Kind {
+	case DriftAdded:
+		return fmt.Sprintf("added %v (%v)", c.Key, c.Upstream)
+	case DriftRemoved:
+		return fmt.Sprintf("removed %v (%v)", c.Key, c.Fixture)
+	}
+	return fmt.Sprintf("%v changed from %v to %v", c.Key, c.Fixture, c.Upstream)
+}
+
+func newDriftDetector(base string) (*driftDetector, error) {
+	u, err := newUpstream(base)
+	if err != nil {
+		return nil, err
+	}
+	return &driftDetector{upstream: u, reports: make(map[string]*driftReport)}, nil
+}
+
+// check compares a response served from fixtures with the upstream's in the
+// background.
+func (d *driftDetector) check(r *http.Request, rt *route, data interface{}) {
+	served, err := jsonTree(data)
+	if err != nil {
+		utils.Log(Verbose, "Couldn't encode response for drift check: %v", err)
+		return
+	}
+
+	// The upstream may answer after the client is done.
+	r = r.WithContext(context.Background())
+	key := r.Method + " " + r.URL.RequestURI()
+
+	d.pending.Add(1)
+	go func() {
+		defer d.pending.Done()
+
+		report := &driftReport{Request: key, Route: rt.path, Time: time.Now(), Changes: []*driftChange{}}
+		resp, body, err := d.upstream.forward(r)
+		switch {
+		case err != nil:
+			report.Error = err.Error()
+		case resp.StatusCode != http.StatusOK:
+			report.Status = resp.StatusCode
+			report.Error = fmt.Sprintf("upstream answered %v", resp.StatusCode)
+		default:
+			report.Status = resp.StatusCode
+			var upstream interface{}
+			if err := json.Unmarshal(body, &upstream); err != nil {
+				report.Error = fmt.Sprintf("upstream response isn't json: %v", err)
+				break
+			}
+			report.Changes = structuralDiff(served, upstream)
+		}
+
+		for _, c := range report.Changes {
+			utils.Log(true, "Drift in %v: %v", key, c)
+		}
+		if report.Error != "" {
+			utils.Log(Verbose, "Couldn't check drift of %v: %v", key, report.Error)
+		}
+		d.record(report)
+	}()
+}
+
+func (d *driftDetector) record(report *driftReport) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if prev, ok := d.reports[report.Request]; ok {
+		report.Checks = prev.Checks
+	}
+	report.Checks++
+	d.reports[report.Request] = report
+}
+
+// wait waits for the checks in flight.
+func (d *driftDetector) wait() {
+	d.pending.Wait()
+}
+
+// list lists the reports by request, optionally only those with drift.
+func (d *driftDetector) list(drifted bool) []*driftReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := []*driftReport{}
+	for _, report := range d.reports {
+		if drifted && len(report.Changes) == 0 {
+			continue
+		}
+		list = append(list, report)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].Request < list[b].Request
+	})
+	return list
+}
+
+// reset drops every report.
+func (d *driftDetector) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reports = make(map[string]*driftReport)
+}
+
+// structuralDiff lists the keys added, removed or changed in type from a
+// served response to an upstream one, sorted by key.
+func structuralDiff(served, upstream interface{}) []*driftChange {
+	changes := make(map[string]*driftChange)
+	diffNode("", served, upstream, changes)
+
+	list := make([]*driftChange, 0, len(changes))
+	for _, c := range changes {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Key != list[b].Key {
+			return list[a].Key < list[b].Key
+		}
+		return list[a].Kind < list[b].Kind
+	})
+	return list
+}
+
+func diffNode(key string, served, upstream interface{}, changes map[string]*driftChange) {
+	add := func(kind, key, fixture, upstream string) {
+		changes[kind+" "+key] = &driftChange{Kind: kind, Key: key, Fixture: fixture, Upstream: upstream}
+	}
+
+	servedType, upstreamType := jsonType(served), jsonType(upstream)
+	if servedType != upstreamType {
+		add(DriftType, key, servedType, upstreamType)
+		return
+	}
+
+	switch s := served.(type) {
+	case map[string]interface{}:
+		u := upstream.(map[string]interface{})
+		for k, v := range s {
+			child := joinKey(key, k)
+			if _, ok := u[k]; !ok {
+				add(DriftRemoved, child, jsonType(v), "")
+				continue
+			}
+			diffNode(child, v, u[k], changes)
+		}
+		for k, v := range u {
+			if _, ok := s[k]; !ok {
+				add(DriftAdded, joinKey(key, k), "", jsonType(v))
+			}
+		}
+	case []interface{}:
+		u := upstream.([]interface{})
+		for i := 0; i < len(s) && i < len(u); i++ {
+			diffNode(key+"[]", s[i], u[i], changes)
+		}
+	}
+}
+
+func joinKey(key, k string) string {
+	if key == "" {
+		return k
+	}
+	return key + "." + k
+}
+
+// jsonType names the json type of a decoded value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonTree turns a response into a generic json tree.
+func jsonTree(data interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	err = json.Unmarshal(encoded, &tree)
+	return tree, err
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	var v interface{}
+	assert.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestStructuralDiff(t *testing.T) {
+	served := decodeJSON(t, `{"a": 1, "b": {"c": "x", "d": [1, 2]}, "e": [{"f": 1}, {"f": 2, "g": true}], "h": null}`)
+	upstream := decodeJSON(t, `{"a": 2, "b": {"c": 3, "d": [], "i": {}}, "e": [{"f": 1, "j": 1}, {"f": 2}], "h": null}`)
+
+	var changes []string
+	for _, c := range structuralDiff(served, upstream) {
+		changes = append(changes, c.Kind+" "+c.Key+" "+c.Fixture+" "+c.Upstream)
+	}
+	assert.Equal(t, []string{
+		"type b.c string number",
+		"added b.i  object",
+		"removed e[].g boolean ",
+		"added e[].j  number",
+	}, changes)
+
+	// Values alone don't drift.
+	assert.Empty(t, structuralDiff(decodeJSON(t, `{"a": [1, "x"]}`), decodeJSON(t, `{"a": [2, "y", 3]}`)))
+}
+
+func TestDriftDetection(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v7/finance/quote":
+			w.Write([]byte(`{"quoteResponse": {"result": [{"symbol": "AAPL", "regularMarketPrice": "1.5", "newField": 1}], "error": null}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	s := newTestServer(t)
+	s.Drift = upstream.URL
+	assert.NoError(t, s.InitRouter())
+
+	// Responses are still served from the fixtures.
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	assert.IsType(t, 0.0, quotePrice(t, body))
+	status, _ = doRequest(t, s, "GET", "/v8/finance/chart/AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	s.drift.wait()
+
+	status, reports := doConfigRequest(t, s, "GET", "/config/drift", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, reports, 2)
+
+	status, reports = doConfigRequest(t, s, "GET", "/config/drift?drifted=true", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, reports, 1)
+	report := reports.([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "GET /v7/finance/quote?symbols=AAPL", report["request"])
+
+	changes := map[string]string{}
+	for _, c := range report["changes"].([]interface{}) {
+		change := c.(map[string]interface{})
+		changes[change["key"].(string)] = change["kind"].(string)
+	}
+	assert.Equal(t, DriftType, changes["quoteResponse.result[].regularMarketPrice"])
+	assert.Equal(t, DriftAdded, changes["quoteResponse.result[].newField"])
+	assert.Equal(t, DriftRemoved, changes["quoteResponse.result[].regularMarketOpen"])
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/drift", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, s.drift.list(false))
+}
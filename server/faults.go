@@ -0,0 +1,174 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+	"github.com/piquette/finance-mock/yfin"
+)
+
+// anySymbol keys faults that apply to every symbol of a route.
+const anySymbol = ""
+
+var faultStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// faultInjector replaces a share of responses with server errors.
+type faultInjector struct {
+	mu     sync.Mutex
+	faults map[faultKey]*faultSampler
+}
+
+// faultKey scopes a fault to a route and symbol.
+type faultKey struct {
+	path   fixture.Path
+	symbol string
+}
+
+// faultSampler decides from a seeded source which requests fail.
+type faultSampler struct {
+	config fixture.Fault
+	rand   *rand.Rand
+}
+
+func newFaultInjector() *faultInjector {
+	return &faultInjector{faults: make(map[faultKey]*faultSampler)}
+}
+
+// set configures a fault for a route, once per listed symbol.
+func (f *faultInjector) set(path fixture.Path, config *fixture.Fault) error {
+	c := *config
+	if c.Rate < 0 || c.Rate > 1 {
+		return fmt.Errorf("rate must be between 0 and 1")
+	}
+	if len(c.Statuses) == 0 {
+		c.Statuses = []int{http.StatusInternalServerError}
+	}
+	for _, status := range c.Statuses {
+		if !containsStatus(faultStatuses, status) {
+			return fmt.Errorf("unsupported fault status: %v", status)
+		}
+	}
+	switch c.Body {
+	case "":
+		c.Body = fixture.FaultBodyJSON
+	case fixture.FaultBodyJSON, fixture.FaultBodyHTML:
+	default:
+		return fmt.Errorf("unknown fault body: %v", c.Body)
+	}
+
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	symbols := c.Symbols
+	if len(symbols) == 0 {
+		symbols = []string{anySymbol}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, symbol := range symbols {
+		sc := c
+		if symbol != anySymbol {
+			sc.Symbols = []string{symbol}
+		}
+		f.faults[faultKey{path, symbol}] = &faultSampler{config: sc, rand: rand.New(rand.NewSource(seed))}
+	}
+	return nil
+}
+
+// clear removes the fault for a route and symbol.
+func (f *faultInjector) clear(path fixture.Path, symbol string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.faults, faultKey{path, symbol})
+}
+
+// config lists the configured faults by route.
+func (f *faultInjector) config() map[fixture.Path][]fixture.Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := make(map[fixture.Path][]fixture.Fault)
+	for key, sampler := range f.faults {
+		c[key.path] = append(c[key.path], sampler.config)
+	}
+	return c
+}
+
+// next decides whether the next request on a route fails. Symbol faults win
+// over route faults, which win over faults for all routes.
+func (f *faultInjector) next(path fixture.Path, symbols []string) *injectedFault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sampler := f.lookup(path, symbols)
+	if sampler == nil {
+		return nil
+	}
+	return sampler.sample()
+}
+
+func (f *faultInjector) lookup(path fixture.Path, symbols []string) *faultSampler {
+	for _, p := range []fixture.Path{path, allRoutes} {
+		for _, symbol := range symbols {
+			if sampler := f.faults[faultKey{p, symbol}]; sampler != nil {
+				return sampler
+			}
+		}
+		if sampler := f.faults[faultKey{p, anySymbol}]; sampler != nil {
+			return sampler
+		}
+	}
+	return nil
+}
+
+// injectedFault is a drawn server error.
+type injectedFault struct {
+	status int
+	body   fixture.FaultBody
+}
+
+func (s *faultSampler) sample() *injectedFault {
+	if s.rand.Float64() >= s.config.Rate {
+		return nil
+	}
+	return &injectedFault{
+		status: s.config.Statuses[s.rand.Intn(len(s.config.Statuses))],
+		body:   s.config.Body,
+	}
+}
+
+// writeFault answers a request with an injected server error.
+func (s *StubServer) writeFault(w http.ResponseWriter, r *http.Request, start time.Time, fault *injectedFault) {
+	utils.Log(Verbose, "Injecting fault: status=%v body=%v", fault.status, fault.body)
+
+	if fault.body == fixture.FaultBodyHTML {
+		status, page := yfin.CreateEdgeErrorPage(fault.status)
+		s.writeRawResponse(w, r, start, status, "text/html;charset=utf-8", page)
+		return
+	}
+
+	status, data := yfin.CreateServerError(fault.status)
+	s.writeResponse(w, r, start, status, data)
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestFaultSample(t *testing.T) {
+	f := newFaultInjector()
+	assert.Nil(t, f.next("/v7/finance/quote", nil))
+
+	config := &fixture.Fault{
+		Rate:     0.5,
+		Statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+		Seed:     7,
+	}
+	assert.NoError(t, f.set("/a", config))
+	assert.NoError(t, f.set("/b", config))
+
+	// Same seed, same faults.
+	var failed int
+	for i := 0; i < 100; i++ {
+		a, b := f.next("/a", nil), f.next("/b", nil)
+		assert.Equal(t, a, b)
+		if a != nil {
+			failed++
+			assert.Contains(t, []int{http.StatusBadGateway, http.StatusServiceUnavailable}, a.status)
+			assert.Equal(t, fixture.FaultBodyJSON, a.body)
+		}
+	}
+	assert.True(t, failed > 20 && failed < 80, "failed %v of 100", failed)
+
+	// Symbol faults win over route faults.
+	assert.NoError(t, f.set("/a", &fixture.Fault{Rate: 0, Symbols: []string{"AAPL"}}))
+	assert.Nil(t, f.next("/a", []string{"AAPL"}))
+
+	assert.Error(t, f.set("/a", &fixture.Fault{Rate: 2}))
+	assert.Error(t, f.set("/a", &fixture.Fault{Rate: 1, Statuses: []int{404}}))
+	assert.Error(t, f.set("/a", &fixture.Fault{Rate: 1, Body: "xml"}))
+}
+
+func TestFaultConfig(t *testing.T) {
+	s := newTestServer(t)
+
+	status, _ := doConfigRequest(t, s, "POST", "/config/faults", url.Values{
+		"path":     {"/v7/finance/quote"},
+		"symbols":  {"AAPL"},
+		"rate":     {"1"},
+		"statuses": {"503"},
+		"body":     {"html"},
+	})
+	assert.Equal(t, http.StatusOK, status)
+
+	req := httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL", nil)
+	w := httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/html"))
+	assert.Contains(t, w.Body.String(), "Will be right back")
+
+	// Other symbols are untouched.
+	status, _ = doRequest(t, s, "GET", "/v7/finance/quote?symbols=SPY")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = doConfigRequest(t, s, "POST", "/config/faults", url.Values{
+		"path": {"/v8/finance/chart"},
+		"rate": {"1"},
+	})
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body := doRequest(t, s, "GET", "/v8/finance/chart/AAPL")
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.NotNil(t, body["error"])
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/faults?path=/v8/finance/chart", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = doRequest(t, s, "GET", "/v8/finance/chart/AAPL")
+	assert.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+var (
+	// errNodeNotFound is returned for fixture nodes that don't exist.
+	errNodeNotFound = fmt.Errorf("fixture node not found")
+
+	// errNodeExists is returned when creating fixture nodes that exist.
+	errNodeExists = fmt.Errorf("fixture node exists")
+)
+
+// fixturesKey is the context key of the fixtures serving a request.
+type fixturesKey struct{}
+
+// withFixtures sets the fixtures serving a request.
+func withFixtures(ctx context.Context, f *fixture.Fixtures) context.Context {
+	return context.WithValue(ctx, fixturesKey{}, f)
+}
+
+// fixturesFrom gets the fixtures serving a request.
+func fixturesFrom(ctx context.Context) *fixture.Fixtures {
+	f, _ := ctx.Value(fixturesKey{}).(*fixture.Fixtures)
+	return f
+}
+
+// fixtureStore holds the loaded fixtures and the runtime overrides on top of
+// them. Writes copy the nodes along the written path and swap in a new
+// snapshot, so requests keep reading the snapshot they started with.
+type fixtureStore struct {
+	mu       sync.Mutex
+	loaded   *fixture.Fixtures
+	snapshot atomic.Value
+	validate func(fixture.ServiceID, fixture.ResourceID, interface{}) error
+}
+
+func newFixtureStore(loaded *fixture.Fixtures, validate func(fixture.ServiceID, fixture.ResourceID, interface{}) error) *fixtureStore {
+	s := &fixtureStore{loaded: loaded, validate: validate}
+	s.snapshot.Store(loaded)
+	return s
+}
+
+// current gets the fixtures snapshot.
+func (s *fixtureStore) current() *fixture.Fixtures {
+	return s.snapshot.Load().(*fixture.Fixtures)
+}
+
+// load replaces the loaded fixtures, dropping every override.
+func (s *fixtureStore) load(loaded *fixture.Fixtures) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaded = loaded
+	s.snapshot.Store(loaded)
+}
+
+// reset drops every override.
+func (s *fixtureStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.Store(s.loaded)
+}
+
+// get reads the node at a path of service, resource and symbol keys.
+func (s *fixtureStore) get(path []string) (interface{}, error) {
+	var node interface{} = fixturesTree(s.current())
+	for _, key := range path {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, errNodeNotFound
+		}
+		if node, ok = m[key]; !ok {
+			return nil, errNodeNotFound
+		}
+	}
+	return node, nil
+}
+
+// update replaces the node at a path with the result of fn, which gets the
+// current node or nil. A nil result deletes the node. It reports whether the
+// node existed before.
+func (s *fixtureStore) update(path []string, fn func(node interface{}, exists bool) (interface{}, error)) (bool, error) {
+	if len(path) < 2 {
+		return false, fmt.Errorf("fixture writes need a service and a resource")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var existed bool
+	var err error
+	tree := setNode(fixturesTree(s.current()), path, func(node interface{}, exists bool) interface{} {
+		existed = exists
+		var v interface{}
+		v, err = fn(node, exists)
+		return v
+	})
+	if err != nil {
+		return existed, err
+	}
+
+	// Validate the written resource before swapping it in.
+	f := fixture.FromTree(tree.(map[string]interface{}))
+	service := fixture.ServiceID(path[0])
+	resource := fixture.ResourceID(path[1])
+	if v, ok := f.Resources[service][resource]; ok {
+		if err = s.validate(service, resource, v); err != nil {
+			return existed, err
+		}
+	}
+
+	s.snapshot.Store(f)
+	return existed, nil
+}
+
+// setNode copies the maps along a path and replaces its last node.
+func setNode(node interface{}, path []string, fn func(node interface{}, exists bool) interface{}) interface{} {
+	m, _ := node.(map[string]interface{})
+	c := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		c[k] = v
+	}
+
+	child, exists := c[path[0]]
+	var v interface{}
+	if len(path) == 1 {
+		v = fn(child, exists)
+	} else {
+		v = setNode(child, path[1:], fn)
+	}
+
+	if v == nil {
+		delete(c, path[0])
+	} else {
+		c[path[0]] = v
+	}
+	return c
+}
+
+// fixturesTree views fixtures as a generic json tree.
+func fixturesTree(f *fixture.Fixtures) map[string]interface{} {
+	tree := make(map[string]interface{}, len(f.Resources))
+	for service, resources := range f.Resources {
+		m := make(map[string]interface{}, len(resources))
+		for resource, v := range resources {
+			m[string(resource)] = v
+		}
+		tree[string(service)] = m
+	}
+	return tree
+}
+
+// validateResource checks that a resource tree has the shape its service's
+// handler reads.
+func (s *StubServer) validateResource(service fixture.ServiceID, resource fixture.ResourceID, tree interface{}) error {
+	if _, ok := s.routes().spec.Services[service]; !ok {
+		return fmt.Errorf("unknown service: %v", service)
+	}
+
+	symbols, ok := tree.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%v/%v must be an object", service, resource)
+	}
+
+	if service != fixture.ServiceYFin {
+		return nil
+	}
+	return validateYFinResource(resource, symbols)
+}
+
+func validateYFinResource(resource fixture.ResourceID, symbols map[string]interface{}) error {
+	for symbol, v := range symbols {
+		node, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v/%v must be an object", resource, symbol)
+		}
+
+		switch resource {
+		case fixture.YFinQuotes:
+			for state, q := range node {
+				if !utils.Contains([]string{"PRE", "REGULAR", "POST"}, state) {
+					return fmt.Errorf("%v/%v/%v is not a market state", resource, symbol, state)
+				}
+				if _, ok := q.(map[string]interface{}); !ok {
+					return fmt.Errorf("%v/%v/%v must be an object", resource, symbol, state)
+				}
+			}
+		case fixture.YFinOptions:
+			for _, format := range []string{"chain", "straddle"} {
+				if v, ok := node[format]; ok {
+					if _, ok := v.(map[string]interface{}); !ok {
+						return fmt.Errorf("%v/%v/%v must be an object", resource, symbol, format)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
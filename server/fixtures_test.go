@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func doFixtureRequest(t *testing.T, s *StubServer, method, target, body string) (int, interface{}) {
+	req := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+	w := httptest.NewRecorder()
+	s.HandleConfigRequest(w, req)
+
+	var v interface{}
+	if w.Body.Len() > 0 {
+		json.Unmarshal(w.Body.Bytes(), &v)
+	}
+	return w.Code, v
+}
+
+func TestFixtureOverrides(t *testing.T) {
+	s := newTestServer(t)
+
+	status, node := doFixtureRequest(t, s, "GET", "/config/fixtures/yfin/quote/AAPL/REGULAR", "")
+	assert.Equal(t, http.StatusOK, status)
+	price := node.(map[string]interface{})["regularMarketPrice"]
+
+	// Patch a single field.
+	status, _ = doFixtureRequest(t, s, "PATCH", "/config/fixtures/yfin/quote/AAPL/REGULAR", `{"regularMarketPrice": 1.5, "bid": null}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	Market = MarketStateRegular
+	defer func() { Market = MarketStatePost }()
+	_, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	q := body["quoteResponse"].(map[string]interface{})["result"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 1.5, q["regularMarketPrice"])
+	assert.Nil(t, q["bid"])
+	assert.Equal(t, "AAPL", q["symbol"])
+
+	// Create a new symbol, slashes escaped.
+	status, _ = doFixtureRequest(t, s, "PUT", "/config/fixtures/yfin/quote/BRK%2FB", `{"REGULAR": {"symbol": "BRK/B"}}`)
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = doFixtureRequest(t, s, "POST", "/config/fixtures/yfin/quote/BRK%2FB", `{"REGULAR": {"symbol": "BRK/B"}}`)
+	assert.Equal(t, http.StatusConflict, status)
+	status, node = doFixtureRequest(t, s, "GET", "/config/fixtures/yfin/quote/BRK%2FB/REGULAR/symbol", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "BRK/B", node)
+
+	// Writes that would break the handlers are rejected.
+	status, _ = doFixtureRequest(t, s, "PUT", "/config/fixtures/yfin/quote/SPY/CLOSED", `{}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = doFixtureRequest(t, s, "PUT", "/config/fixtures/yfin/chart", `[]`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = doFixtureRequest(t, s, "PUT", "/config/fixtures/nope/quote", `{}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = doFixtureRequest(t, s, "PATCH", "/config/fixtures/yfin/quote/MSFT", `{}`)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Delete the chart, misses get the error node.
+	status, _ = doFixtureRequest(t, s, "DELETE", "/config/fixtures/yfin/chart/AAPL", "")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = doFixtureRequest(t, s, "DELETE", "/config/fixtures/yfin/chart/AAPL", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	_, body = doRequest(t, s, "GET", "/v8/finance/chart/AAPL")
+	assert.Equal(t, "Not Found", body["chart"].(map[string]interface{})["result"].([]interface{})[0].(map[string]interface{})["code"])
+
+	// The loaded fixtures are untouched and come back on reset.
+	loaded := s.Fixtures.Resources["yfin"]["quote"].(map[string]interface{})["AAPL"].(map[string]interface{})["REGULAR"]
+	assert.Equal(t, price, loaded.(map[string]interface{})["regularMarketPrice"])
+
+	status, _ = doFixtureRequest(t, s, "DELETE", "/config/fixtures", "")
+	assert.Equal(t, http.StatusOK, status)
+	status, node = doFixtureRequest(t, s, "GET", "/config/fixtures/yfin/quote/AAPL/REGULAR/regularMarketPrice", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, price, node)
+	status, _ = doFixtureRequest(t, s, "GET", "/config/fixtures/yfin/quote/BRK%2FB", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
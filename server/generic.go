@@ -0,0 +1,191 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+// resultPlaceholder marks where an envelope holds the lookup result.
+const resultPlaceholder = "{{result}}"
+
+// GenericService serves a service declared only in the spec, looking the
+// responses of its operations up in its resource tree.
+type GenericService struct {
+	ID        fixture.ServiceID
+	Service   *fixture.Service
+	Resources fixture.Resources
+}
+
+// newGenericService checks the lookups of a service's operations.
+func newGenericService(id fixture.ServiceID, service *fixture.Service, resources fixture.Resources) (*GenericService, error) {
+	g := &GenericService{ID: id, Service: service, Resources: resources}
+	for path, item := range service.Paths {
+		var names []string
+		for _, m := range pathParameterPattern.FindAllStringSubmatch(string(path), -1) {
+			names = append(names, m[1])
+		}
+		for method, op := range item {
+			if op.Lookup == nil {
+				continue
+			}
+			for _, key := range op.Lookup.Key {
+				switch {
+				case strings.HasPrefix(key, "path."):
+					if !utils.Contains(names, strings.TrimPrefix(key, "path.")) {
+						return nil, fmt.Errorf("lookup of %v %v has an unknown path parameter: %v", method, path, key)
+					}
+				case strings.HasPrefix(key, "query."):
+				default:
+					return nil, fmt.Errorf("lookup of %v %v has an invalid key: %v", method, path, key)
+				}
+			}
+		}
+	}
+	return g, nil
+}
+
+// Handle looks up the response of a request.
+func (g *GenericService) Handle(req *http.Request, match *RouteMatch) (statusCode int, responseData interface{}) {
+
+	// Serve the fixtures snapshot of the request.
+	resources := g.Resources
+	if f := fixturesFrom(req.Context()); f != nil {
+		resources = f.Resources[g.ID]
+	}
+
+	op := match.Operation
+	if op == nil {
+		utils.Log(Verbose, "Couldn't figure out what %v operation was requested", g.ID)
+		return http.StatusInternalServerError, nil
+	}
+
+	params, perr, err := requestParams(req, op)
+	if err != nil {
+		return http.StatusInternalServerError, nil
+	}
+	if perr != nil {
+		return perr.response()
+	}
+
+	tree := resources[op.ResourceID]
+	lookup := op.Lookup
+	if lookup == nil {
+		lookup = &fixture.Lookup{}
+	}
+
+	// Each key is a level of the tree, lists look up each item.
+	var keys [][]string
+	var list bool
+	for _, key := range lookup.Key {
+		values, isList := lookupKey(key, match, params)
+		if values == nil {
+			return missResponse(tree, lookup)
+		}
+		keys = append(keys, values)
+		list = list || isList
+	}
+
+	found := lookupTree(tree, keys)
+	if list {
+		return http.StatusOK, envelope(lookup.Envelope, found)
+	}
+	if len(found) == 0 {
+		return missResponse(tree, lookup)
+	}
+	return http.StatusOK, envelope(lookup.Envelope, found[0])
+}
+
+// lookupKey gets the values of a lookup key and whether they're a list.
+func lookupKey(key string, match *RouteMatch, params map[string]interface{}) ([]string, bool) {
+	if strings.HasPrefix(key, "path.") {
+		v, ok := match.Params[strings.TrimPrefix(key, "path.")]
+		if !ok {
+			return nil, false
+		}
+		return []string{v}, false
+	}
+
+	switch v := params[strings.TrimPrefix(key, "query.")].(type) {
+	case nil:
+		return nil, false
+	case []string:
+		return v, true
+	default:
+		return []string{fmt.Sprint(v)}, false
+	}
+}
+
+// lookupTree finds the nodes below a tree at every combination of keys.
+func lookupTree(node interface{}, keys [][]string) []interface{} {
+	if len(keys) == 0 {
+		return []interface{}{node}
+	}
+
+	found := []interface{}{}
+	m, _ := node.(map[string]interface{})
+	for _, key := range keys[0] {
+		if child, ok := m[key]; ok {
+			found = append(found, lookupTree(child, keys[1:])...)
+		}
+	}
+	return found
+}
+
+// missResponse serves the miss node of a lookup.
+func missResponse(tree interface{}, lookup *fixture.Lookup) (int, interface{}) {
+	status := lookup.MissStatus
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+	if lookup.Miss == "" {
+		return status, nil
+	}
+
+	var keys [][]string
+	for _, key := range strings.Split(lookup.Miss, "/") {
+		keys = append(keys, []string{key})
+	}
+	found := lookupTree(tree, keys)
+	if len(found) == 0 {
+		return status, nil
+	}
+	return status, found[0]
+}
+
+// envelope writes a result into an envelope where it holds the result
+// placeholder. Without an envelope the result is served as it is.
+func envelope(env, result interface{}) interface{} {
+	if env == nil {
+		return result
+	}
+	return fillEnvelope(env, result)
+}
+
+func fillEnvelope(env, result interface{}) interface{} {
+	switch t := env.(type) {
+	case string:
+		if t == resultPlaceholder {
+			return result
+		}
+		return t
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = fillEnvelope(v, result)
+		}
+		return m
+	case map[interface{}]interface{}:
+		return fillEnvelope(utils.NormalizeYAML(t), result)
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, v := range t {
+			list[i] = fillEnvelope(v, result)
+		}
+		return list
+	}
+	return env
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const testGenericSpec = `
+services:
+  crypto:
+    paths:
+      "/v1/coins/{coin}/{currency}":
+        get:
+          resource: prices
+          lookup:
+            key: [path.coin, path.currency]
+            envelope: {data: "{{result}}", error: null}
+            miss: error
+      "/v1/prices":
+        get:
+          parameters:
+          - name: coins
+            required: true
+            type: csv-list
+          - name: currency
+            default: usd
+          resource: prices
+          lookup:
+            key: [query.coins, query.currency]
+            envelope: {data: {items: "{{result}}"}}
+      "/v1/markets":
+        get:
+          resource: markets
+`
+
+const testGenericFixtures = `{"resources": {"crypto": {
+	"prices": {
+		"btc": {"usd": {"price": 6500}, "eur": {"price": 5600}},
+		"eth": {"usd": {"price": 470}},
+		"error": {"code": "not-found"}
+	},
+	"markets": {"open": true}
+}}}`
+
+func newGenericTestServer(t *testing.T) *StubServer {
+	var spec fixture.Spec
+	assert.NoError(t, yaml.Unmarshal([]byte(testGenericSpec), &spec))
+	var fixtures fixture.Fixtures
+	assert.NoError(t, json.Unmarshal([]byte(testGenericFixtures), &fixtures))
+
+	s := &StubServer{Spec: &spec, Fixtures: &fixtures}
+	assert.NoError(t, s.InitRouter())
+	return s
+}
+
+func TestGenericLookupByPath(t *testing.T) {
+	s := newGenericTestServer(t)
+
+	status, body := doRequest(t, s, "GET", "/v1/coins/btc/eur")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"data": map[string]interface{}{"price": 5600.0}, "error": nil}, body)
+
+	// Misses serve the miss node.
+	status, body = doRequest(t, s, "GET", "/v1/coins/eth/eur")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, map[string]interface{}{"code": "not-found"}, body)
+}
+
+func TestGenericLookupByQuery(t *testing.T) {
+	s := newGenericTestServer(t)
+
+	// Lists look up each item, skipping misses, and defaults fill in keys.
+	status, body := doRequest(t, s, "GET", "/v1/prices?coins=btc,doge,eth")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"data": map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"price": 6500.0},
+		map[string]interface{}{"price": 470.0},
+	}}}, body)
+
+	status, body = doRequest(t, s, "GET", "/v1/prices?coins=doge")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"data": map[string]interface{}{"items": []interface{}{}}}, body)
+
+	// Without a lookup the whole resource is served.
+	status, body = doRequest(t, s, "GET", "/v1/markets")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"open": true}, body)
+}
+
+func TestGenericLookupErrors(t *testing.T) {
+	for _, key := range []string{"path.missing", "header.X-Coin"} {
+		service := &fixture.Service{Paths: map[fixture.Path]fixture.PathItem{
+			"/v1/coins/{coin}": {"GET": {Lookup: &fixture.Lookup{Key: []string{key}}}},
+		}}
+		_, err := newGenericService("crypto", service, nil)
+		assert.Error(t, err)
+	}
+}
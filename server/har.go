@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+// DefaultHARIgnore lists the volatile query parameters ignored when matching
+// requests to HAR entries.
+var DefaultHARIgnore = []string{"crumb", "corsDomain", ".tsrc", "_"}
+
+// harSkipHeaders are captured response headers that aren't replayed.
+var harSkipHeaders = []string{
+	"Connection",
+	"Content-Encoding",
+	"Content-Length",
+	"Keep-Alive",
+	"Transfer-Encoding",
+}
+
+// harReplay serves captured traffic. Requests match entries by method, path
+// and query without the ignored parameters. Entries with the same match are
+// replayed in order, and the last one repeats.
+type harReplay struct {
+	mu      sync.Mutex
+	entries []*fixture.HAREntry
+	ignore  []string
+	index   map[string][]*fixture.HAREntry
+	served  map[string]int
+}
+
+// harInfo reports a HAR replay.
+type harInfo struct {
+	Entries int            `json:"entries"`
+	Ignore  []string       `json:"ignore"`
+	Served  map[string]int `json:"served"`
+}
+
+func newHARReplay(har *fixture.HAR, ignore []string) (*harReplay, error) {
+	if har.Log == nil {
+		return nil, fmt.Errorf("har has no log")
+	}
+
+	h := &harReplay{}
+	for i, e := range har.Log.Entries {
+		if e.Request == nil || e.Response == nil {
+			return nil, fmt.Errorf("har entry %d has no request or response", i)
+		}
+		if _, err := url.Parse(e.Request.URL); err != nil {
+			return nil, fmt.Errorf("har entry %d: %v", i, err)
+		}
+		// Requests the browser aborted have no response.
+		if e.Response.Status == 0 {
+			continue
+		}
+		h.entries = append(h.entries, e)
+	}
+	h.setIgnore(ignore)
+	return h, nil
+}
+
+// setIgnore sets the ignored query parameters and rewinds the replay.
+func (h *harReplay) setIgnore(ignore []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.ignore = ignore
+	h.index = make(map[string][]*fixture.HAREntry)
+	h.served = make(map[string]int)
+	for _, e := range h.entries {
+		u, _ := url.Parse(e.Request.URL)
+		key := h.key(e.Request.Method, u)
+		h.index[key] = append(h.index[key], e)
+	}
+}
+
+// key normalizes a request: query parameters are sorted and the ignored ones
+// dropped.
+func (h *harReplay) key(method string, u *url.URL) string {
+	query := u.Query()
+	for _, name := range h.ignore {
+		query.Del(name)
+	}
+	return strings.ToUpper(method) + " " + u.Path + "?" + query.Encode()
+}
+
+// next finds the entry to replay for a request.
+func (h *harReplay) next(r *http.Request) *fixture.HAREntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.key(r.Method, r.URL)
+	entries := h.index[key]
+	if len(entries) == 0 {
+		return nil
+	}
+
+	i := h.served[key]
+	if i >= len(entries) {
+		i = len(entries) - 1
+	}
+	h.served[key]++
+	return entries[i]
+}
+
+func (h *harReplay) info() *harInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	served := make(map[string]int, len(h.served))
+	for k, v := range h.served {
+		served[k] = v
+	}
+	return &harInfo{Entries: len(h.entries), Ignore: h.ignore, Served: served}
+}
+
+// writeHAREntry replays a captured response.
+func (s *StubServer) writeHAREntry(w http.ResponseWriter, r *http.Request, start time.Time, e *fixture.HAREntry) {
+	utils.Log(Verbose, "Replaying har entry: %v %v", e.Request.Method, e.Request.URL)
+
+	var data []byte
+	contentType := "application/json;charset=utf-8"
+	if c := e.Response.Content; c != nil {
+		data = []byte(c.Text)
+		if c.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(c.Text)
+			if err != nil {
+				utils.Log(Verbose, "Couldn't decode har content: %v", err)
+				s.writeResponse(w, r, start, http.StatusInternalServerError, nil)
+				return
+			}
+			data = decoded
+		}
+		if c.MimeType != "" {
+			contentType = c.MimeType
+		}
+	}
+
+	for _, header := range e.Response.Headers {
+		name := http.CanonicalHeaderKey(header.Name)
+		if utils.Contains(harSkipHeaders, name) || name == "Content-Type" {
+			continue
+		}
+		w.Header().Add(name, header.Value)
+	}
+	s.writeRawResponse(w, r, start, e.Response.Status, contentType, data)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+const testHAR = `{"log": {"entries": [
+	{
+		"request": {"method": "GET", "url": "https://query1.finance.yahoo.com/v7/finance/quote?symbols=AAPL&crumb=abc"},
+		"response": {"status": 200, "headers": [{"name": "X-Captured", "value": "1"}, {"name": "Content-Length", "value": "999"}],
+			"content": {"mimeType": "application/json", "text": "{\"quoteResponse\": {\"result\": [{\"symbol\": \"AAPL\", \"regularMarketPrice\": 1.5}], \"error\": null}}"}}
+	},
+	{
+		"request": {"method": "GET", "url": "https://query1.finance.yahoo.com/v7/finance/quote?crumb=def&symbols=AAPL"},
+		"response": {"status": 200, "content": {"mimeType": "application/json", "text": "eyJxdW90ZVJlc3BvbnNlIjogeyJyZXN1bHQiOiBbeyJzeW1ib2wiOiAiQUFQTCIsICJyZWd1bGFyTWFya2V0UHJpY2UiOiAyLjV9XSwgImVycm9yIjogbnVsbH19", "encoding": "base64"}}
+	},
+	{
+		"request": {"method": "GET", "url": "https://query1.finance.yahoo.com/v1/finance/trending/US"},
+		"response": {"status": 200, "content": {"mimeType": "application/json", "text": "{\"trending\": true}"}}
+	},
+	{
+		"request": {"method": "GET", "url": "https://query1.finance.yahoo.com/v1/aborted"},
+		"response": {"status": 0}
+	}
+]}}`
+
+func newHARServer(t *testing.T, ignore []string) *StubServer {
+	s := newTestServer(t)
+	var har fixture.HAR
+	assert.NoError(t, json.Unmarshal([]byte(testHAR), &har))
+	s.HAR = &har
+	s.HARIgnore = ignore
+	assert.NoError(t, s.InitRouter())
+	return s
+}
+
+func TestHARReplay(t *testing.T) {
+	s := newHARServer(t, nil)
+
+	req := httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL", nil)
+	w := httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Captured"))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 1.5, quotePrice(t, body))
+
+	// Matching entries replay in order, and the last one repeats.
+	for i := 0; i < 2; i++ {
+		status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL&crumb=xyz")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 2.5, quotePrice(t, body))
+	}
+
+	// Paths missing from the spec are served too.
+	status, body := doRequest(t, s, "GET", "/v1/finance/trending/US")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, body["trending"])
+
+	// Other requests fall back to the fixtures.
+	status, body = doRequest(t, s, "GET", "/v7/finance/quote?symbols=SPY")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "SPY", body["quoteResponse"].(map[string]interface{})["result"].([]interface{})[0].(map[string]interface{})["symbol"])
+
+	status, _ = doRequest(t, s, "GET", "/v1/aborted")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHARIgnore(t *testing.T) {
+	s := newHARServer(t, []string{})
+
+	// Without ignored parameters the crumb has to match.
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL&crumb=xyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEqual(t, 1.5, quotePrice(t, body))
+	assert.NotEqual(t, 2.5, quotePrice(t, body))
+	assert.Empty(t, s.har.info().Served)
+
+	status, body = doRequest(t, s, "GET", "/v7/finance/quote?crumb=def&symbols=AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2.5, quotePrice(t, body))
+
+	// Ignoring it at runtime rewinds the replay.
+	status, info := doConfigRequest(t, s, "POST", "/config/har", url.Values{"ignore": {"crumb"}})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []interface{}{"crumb"}, info.(map[string]interface{})["ignore"])
+
+	status, body = doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL&crumb=xyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1.5, quotePrice(t, body))
+}
+
+func TestHARInvalid(t *testing.T) {
+	s := newTestServer(t)
+	s.HAR = &fixture.HAR{}
+	assert.Error(t, s.InitRouter())
+
+	var har fixture.HAR
+	assert.NoError(t, json.NewDecoder(strings.NewReader(`{"log": {"entries": [{"request": {"method": "GET", "url": "/"}}]}}`)).Decode(&har))
+	s.HAR = &har
+	assert.Error(t, s.InitRouter())
+
+	s = newTestServer(t)
+	status, _ := doConfigRequest(t, s, "GET", "/config/har", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
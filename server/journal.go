@@ -0,0 +1,297 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+const (
+	// maxJournalEntries bounds the request journal.
+	maxJournalEntries = 10000
+
+	// maxNearMisses bounds the near misses reported by a failed verification.
+	maxNearMisses = 5
+)
+
+// journal records the requests handled by the stub server.
+type journal struct {
+	mu      sync.Mutex
+	entries []*journalEntry
+	nextID  int
+}
+
+// journalEntry is a recorded request.
+type journalEntry struct {
+	ID      int                    `json:"id"`
+	Time    time.Time              `json:"time"`
+	Method  string                 `json:"method"`
+	Path    string                 `json:"path"`
+	Query   map[string]interface{} `json:"query"`
+	Headers http.Header            `json:"headers"`
+	Route   fixture.Path           `json:"route"`
+	Stub    string                 `json:"stub,omitempty"`
+	Symbols []string               `json:"symbols"`
+	Session string                 `json:"session,omitempty"`
+	Status  int                    `json:"status"`
+	Latency fixture.Duration       `json:"latency"`
+}
+
+// requestMatcher selects journal entries. Empty fields match anything.
+type requestMatcher struct {
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path,omitempty"`
+	Route   fixture.Path      `json:"route,omitempty"`
+	Stub    string            `json:"stub,omitempty"`
+	Session string            `json:"session,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Symbol  string            `json:"symbol,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// verification checks the number of requests matching a matcher. Without
+// bounds it checks for at least one.
+type verification struct {
+	requestMatcher
+	Count   *int `json:"count,omitempty"`
+	AtLeast *int `json:"atLeast,omitempty"`
+	AtMost  *int `json:"atMost,omitempty"`
+}
+
+// verificationResult reports a verification, with the closest requests that
+// didn't match when it failed.
+type verificationResult struct {
+	OK         bool         `json:"ok"`
+	Count      int          `json:"count"`
+	Expected   string       `json:"expected"`
+	NearMisses []*nearMiss  `json:"nearMisses,omitempty"`
+	Matcher    verification `json:"matcher"`
+}
+
+// nearMiss is a request with the fields that kept it from matching.
+type nearMiss struct {
+	Request *journalEntry  `json:"request"`
+	Diff    []*matcherDiff `json:"diff"`
+}
+
+// matcherDiff is a field whose value differs from the matcher.
+type matcherDiff struct {
+	Field    string      `json:"field"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+}
+
+func newJournal() *journal {
+	return &journal{}
+}
+
+// record adds an entry, dropping the oldest when full.
+func (j *journal) record(e *journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.nextID++
+	e.ID = j.nextID
+	j.entries = append(j.entries, e)
+	if len(j.entries) > maxJournalEntries {
+		j.entries = j.entries[len(j.entries)-maxJournalEntries:]
+	}
+}
+
+// reset drops every entry.
+func (j *journal) reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = nil
+}
+
+// find lists the entries matching a matcher, oldest first.
+func (j *journal) find(m *requestMatcher) []*journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	found := []*journalEntry{}
+	for _, e := range j.entries {
+		if len(m.diff(e)) == 0 {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// verify checks the number of entries matching a verification.
+func (j *journal) verify(v *verification) *verificationResult {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	result := &verificationResult{Matcher: *v}
+	var misses []*nearMiss
+	for _, e := range j.entries {
+		diff := v.diff(e)
+		if len(diff) == 0 {
+			result.Count++
+			continue
+		}
+		misses = append(misses, &nearMiss{Request: e, Diff: diff})
+	}
+
+	result.OK, result.Expected = v.check(result.Count)
+	if !result.OK {
+		// The fewer fields differ, the nearer the miss.
+		sort.SliceStable(misses, func(a, b int) bool {
+			return len(misses[a].Diff) < len(misses[b].Diff)
+		})
+		if len(misses) > maxNearMisses {
+			misses = misses[:maxNearMisses]
+		}
+		result.NearMisses = misses
+	}
+	return result
+}
+
+func (v *verification) check(count int) (bool, string) {
+	var expected []string
+	ok := true
+	if v.Count != nil {
+		expected = append(expected, fmt.Sprintf("exactly %d", *v.Count))
+		ok = ok && count == *v.Count
+	}
+	if v.AtLeast != nil {
+		expected = append(expected, fmt.Sprintf("at least %d", *v.AtLeast))
+		ok = ok && count >= *v.AtLeast
+	}
+	if v.AtMost != nil {
+		expected = append(expected, fmt.Sprintf("at most %d", *v.AtMost))
+		ok = ok && count <= *v.AtMost
+	}
+	if len(expected) == 0 {
+		expected = append(expected, "at least 1")
+		ok = count >= 1
+	}
+	return ok, strings.Join(expected, " and ")
+}
+
+// diff lists the fields of an entry that don't match.
+func (m *requestMatcher) diff(e *journalEntry) []*matcherDiff {
+	var diff []*matcherDiff
+	add := func(field string, expected, actual interface{}) {
+		diff = append(diff, &matcherDiff{Field: field, Expected: expected, Actual: actual})
+	}
+
+	if m.Method != "" && !strings.EqualFold(m.Method, e.Method) {
+		add("method", m.Method, e.Method)
+	}
+	if m.Path != "" && m.Path != e.Path {
+		add("path", m.Path, e.Path)
+	}
+	if m.Route != "" && m.Route != e.Route {
+		add("route", m.Route, e.Route)
+	}
+	if m.Stub != "" && m.Stub != e.Stub {
+		add("stub", m.Stub, e.Stub)
+	}
+	if m.Session != "" && m.Session != e.Session {
+		add("session", m.Session, e.Session)
+	}
+	if m.Status != 0 && m.Status != e.Status {
+		add("status", m.Status, e.Status)
+	}
+	if m.Symbol != "" && !utils.Contains(e.Symbols, m.Symbol) {
+		add("symbol", m.Symbol, e.Symbols)
+	}
+	for _, name := range sortedKeys(m.Query) {
+		actual, _ := e.Query[name].(string)
+		if actual != m.Query[name] {
+			add("query."+name, m.Query[name], e.Query[name])
+		}
+	}
+	for _, name := range sortedKeys(m.Headers) {
+		if actual := e.Headers.Get(name); actual != m.Headers[name] {
+			add("headers."+name, m.Headers[name], actual)
+		}
+	}
+	return diff
+}
+
+// parseRequestMatcher reads a matcher from query parameters. Query and header
+// values are given as query.name=value and headers.Name=value.
+func parseRequestMatcher(r *http.Request) (*requestMatcher, error) {
+	q := r.URL.Query()
+	m := &requestMatcher{
+		Method:  q.Get("method"),
+		Path:    q.Get("path"),
+		Route:   fixture.Path(q.Get("route")),
+		Stub:    q.Get("stub"),
+		Session: q.Get("session"),
+		Symbol:  q.Get("symbol"),
+		Query:   map[string]string{},
+		Headers: map[string]string{},
+	}
+
+	if v := q.Get("status"); v != "" {
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status: %v", v)
+		}
+		m.Status = status
+	}
+
+	for k, v := range q {
+		switch {
+		case strings.HasPrefix(k, "query."):
+			m.Query[strings.TrimPrefix(k, "query.")] = v[0]
+		case strings.HasPrefix(k, "headers."):
+			m.Headers[strings.TrimPrefix(k, "headers.")] = v[0]
+		}
+	}
+	return m, nil
+}
+
+// recordRequest adds a handled request to the journal.
+func (s *StubServer) recordRequest(r *http.Request, rt *route, stub *fixture.Stub, status int, start time.Time) {
+	query, err := utils.ParseFormString(r.URL.RawQuery)
+	if err != nil {
+		query = map[string]interface{}{}
+	}
+
+	headers := make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		headers[k] = v
+	}
+
+	e := &journalEntry{
+		Time:    start,
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   query,
+		Headers: headers,
+		Session: sessionID(r),
+		Status:  status,
+		Latency: fixture.Duration(time.Since(start)),
+	}
+	if stub != nil {
+		e.Stub = stub.Name
+	}
+	if rt != nil {
+		e.Route = rt.path
+		e.Symbols = requestSymbols(r, rt)
+	}
+	s.journal.record(e)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL,SPY&crumb=x", nil)
+	req.Header.Set("User-Agent", "finance-go")
+	s.HandleRequest(httptest.NewRecorder(), req)
+	doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	doRequest(t, s, "GET", "/v8/finance/chart/SPY?interval=1d")
+	doRequest(t, s, "GET", "/v9/nope")
+
+	entries := s.journal.find(&requestMatcher{})
+	assert.Len(t, entries, 4)
+	e := entries[0]
+	assert.Equal(t, "GET", e.Method)
+	assert.Equal(t, "/v7/finance/quote", e.Path)
+	assert.Equal(t, map[string]interface{}{"symbols": "AAPL,SPY", "crumb": "x"}, e.Query)
+	assert.Equal(t, "finance-go", e.Headers.Get("User-Agent"))
+	assert.Equal(t, []string{"AAPL", "SPY"}, e.Symbols)
+	assert.Equal(t, http.StatusOK, e.Status)
+	assert.EqualValues(t, "/v7/finance/quote", e.Route)
+	assert.Equal(t, http.StatusNotFound, entries[3].Status)
+
+	status, body := doConfigRequest(t, s, "GET", "/config/requests?symbol=SPY", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body, 2)
+
+	status, body = doConfigRequest(t, s, "GET", "/config/requests?route=/v7/finance/quote&query.crumb=x&headers.User-Agent=finance-go", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body, 1)
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/requests", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, s.journal.find(&requestMatcher{}))
+}
+
+func TestJournalVerify(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	doRequest(t, s, "GET", "/v7/finance/quote?symbols=SPY")
+
+	verify := func(v string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/config/requests/verify", bytes.NewReader([]byte(v)))
+		w := httptest.NewRecorder()
+		s.HandleConfigRequest(w, req)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	status, body := verify(`{"route": "/v7/finance/quote", "count": 2}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, body["ok"])
+
+	status, body = verify(`{"route": "/v7/finance/quote", "query": {"symbols": "AAPL,SPY"}, "atLeast": 1}`)
+	assert.Equal(t, http.StatusExpectationFailed, status)
+	assert.Equal(t, false, body["ok"])
+	assert.Equal(t, 0.0, body["count"])
+	assert.Equal(t, "at least 1", body["expected"])
+
+	misses := body["nearMisses"].([]interface{})
+	assert.Len(t, misses, 2)
+	diff := misses[0].(map[string]interface{})["diff"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"field":    "query.symbols",
+		"expected": "AAPL,SPY",
+		"actual":   "AAPL",
+	}, diff[0])
+}
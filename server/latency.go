@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+)
+
+// allRoutes keys settings that apply to every route without its own.
+const allRoutes fixture.Path = ""
+
+// latencyInjector delays responses per route.
+type latencyInjector struct {
+	mu     sync.Mutex
+	routes map[fixture.Path]*latencySampler
+}
+
+// latencySampler draws delays from a seeded distribution.
+type latencySampler struct {
+	config fixture.Latency
+	rand   *rand.Rand
+}
+
+func newLatencyInjector() *latencyInjector {
+	return &latencyInjector{routes: make(map[fixture.Path]*latencySampler)}
+}
+
+// set configures the latency for a route.
+func (l *latencyInjector) set(path fixture.Path, config *fixture.Latency) error {
+	c := *config
+	switch c.Distribution {
+	case "":
+		c.Distribution = fixture.LatencyFixed
+		if c.Jitter > 0 {
+			c.Distribution = fixture.LatencyUniform
+		}
+	case fixture.LatencyFixed, fixture.LatencyUniform, fixture.LatencyNormal, fixture.LatencyLogNormal:
+	default:
+		return fmt.Errorf("unknown distribution: %v", c.Distribution)
+	}
+	if c.Delay < 0 || c.Jitter < 0 {
+		return fmt.Errorf("delay and jitter must not be negative")
+	}
+	if c.Distribution == fixture.LatencyLogNormal && c.Delay == 0 {
+		return fmt.Errorf("lognormal latency needs a delay")
+	}
+
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.routes[path] = &latencySampler{config: c, rand: rand.New(rand.NewSource(seed))}
+	return nil
+}
+
+// clear removes the latency for a route.
+func (l *latencyInjector) clear(path fixture.Path) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.routes, path)
+}
+
+// config lists the latency of every configured route.
+func (l *latencyInjector) config() map[fixture.Path]fixture.Latency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := make(map[fixture.Path]fixture.Latency)
+	for path, sampler := range l.routes {
+		c[path] = sampler.config
+	}
+	return c
+}
+
+// next draws the delay for the next request on a route.
+func (l *latencyInjector) next(path fixture.Path) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sampler := l.routes[path]
+	if sampler == nil {
+		sampler = l.routes[allRoutes]
+	}
+	if sampler == nil {
+		return 0
+	}
+	return sampler.sample()
+}
+
+// wait sleeps for the next delay of a route. It returns false if the
+// request was cancelled in the meantime.
+func (l *latencyInjector) wait(ctx context.Context, path fixture.Path) bool {
+	d := l.next(path)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *latencySampler) sample() time.Duration {
+	delay := float64(s.config.Delay)
+	jitter := float64(s.config.Jitter)
+
+	var d float64
+	switch s.config.Distribution {
+	case fixture.LatencyUniform:
+		d = delay + (s.rand.Float64()*2-1)*jitter
+	case fixture.LatencyNormal:
+		d = delay + s.rand.NormFloat64()*jitter
+	case fixture.LatencyLogNormal:
+		// Pick mu and sigma so the samples have the configured mean and
+		// standard deviation.
+		sigma2 := math.Log(1 + (jitter*jitter)/(delay*delay))
+		mu := math.Log(delay) - sigma2/2
+		d = math.Exp(mu + s.rand.NormFloat64()*math.Sqrt(sigma2))
+	default:
+		d = delay
+	}
+
+	if d < 0 {
+		return 0
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestLatencySample(t *testing.T) {
+	l := newLatencyInjector()
+	assert.Equal(t, time.Duration(0), l.next("/v7/finance/quote"))
+
+	assert.NoError(t, l.set("/v7/finance/quote", &fixture.Latency{
+		Delay: fixture.Duration(100 * time.Millisecond),
+	}))
+	assert.Equal(t, 100*time.Millisecond, l.next("/v7/finance/quote"))
+
+	// Route settings win over the all routes default.
+	assert.NoError(t, l.set(allRoutes, &fixture.Latency{Delay: fixture.Duration(time.Second)}))
+	assert.Equal(t, 100*time.Millisecond, l.next("/v7/finance/quote"))
+	assert.Equal(t, time.Second, l.next("/v8/finance/chart"))
+
+	for _, dist := range []fixture.LatencyDistribution{fixture.LatencyUniform, fixture.LatencyNormal, fixture.LatencyLogNormal} {
+		config := &fixture.Latency{
+			Distribution: dist,
+			Delay:        fixture.Duration(100 * time.Millisecond),
+			Jitter:       fixture.Duration(20 * time.Millisecond),
+			Seed:         42,
+		}
+
+		// Same seed, same delays.
+		assert.NoError(t, l.set("/a", config))
+		assert.NoError(t, l.set("/b", config))
+		for i := 0; i < 10; i++ {
+			d := l.next("/a")
+			assert.Equal(t, d, l.next("/b"))
+			assert.True(t, d >= 0)
+			if dist == fixture.LatencyUniform {
+				assert.InDelta(t, 100*time.Millisecond, d, float64(20*time.Millisecond))
+			}
+		}
+	}
+
+	assert.Error(t, l.set("/a", &fixture.Latency{Distribution: "poisson"}))
+	assert.Error(t, l.set("/a", &fixture.Latency{Distribution: fixture.LatencyLogNormal}))
+}
+
+func TestLatencyWaitCancelled(t *testing.T) {
+	l := newLatencyInjector()
+	assert.NoError(t, l.set(allRoutes, &fixture.Latency{Delay: fixture.Duration(time.Minute)}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+
+	start := time.Now()
+	assert.False(t, l.wait(ctx, "/v7/finance/quote"))
+	assert.True(t, time.Since(start) < time.Minute)
+}
+
+func TestLatencyConfig(t *testing.T) {
+	s := newTestServer(t)
+
+	status, _ := doConfigRequest(t, s, "POST", "/config/latency",
+		url.Values{"path": {"/v7/finance/quote"}, "delay": {"50ms"}})
+	assert.Equal(t, http.StatusOK, status)
+
+	start := time.Now()
+	status, _ = doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	status, _ = doConfigRequest(t, s, "POST", "/config/latency",
+		url.Values{"path": {"/v7/finance/nope"}, "delay": {"50ms"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/latency?path=/v7/finance/quote", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, s.routes().latency.config())
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest("POST", "/v7/finance/quote?symbols=AAPL", nil)
+	w := httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+
+	// Unrouted paths are still not found.
+	status, _ := doRequest(t, s, "POST", "/v7/finance/nothing")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestOptionsAndHead(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleRequest))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("OPTIONS", ts.URL+"/v8/finance/chart/AAPL", nil)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "GET, HEAD, OPTIONS", resp.Header.Get("Allow"))
+
+	// HEAD is served by GET without the body.
+	resp, err = http.Head(ts.URL + "/v7/finance/quote?symbols=AAPL")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, body)
+	assert.Equal(t, fixture.Path("/v7/finance/quote"), s.journal.find(&requestMatcher{Method: "HEAD"})[0].Route)
+}
+
+func TestMethodSettingsConflict(t *testing.T) {
+	newServer := func(get, post *fixture.Latency) error {
+		spec := &fixture.Spec{Services: map[fixture.ServiceID]*fixture.Service{
+			"crypto": {Paths: map[fixture.Path]fixture.PathItem{
+				"/v1/prices": {
+					"GET":  {Latency: get, Example: &fixture.Step{Body: "ok"}},
+					"POST": {Latency: post, Example: &fixture.Step{Body: "ok"}},
+				},
+			}},
+		}}
+		s := &StubServer{Spec: spec, Fixtures: &fixture.Fixtures{}}
+		return s.InitRouter()
+	}
+
+	slow := &fixture.Latency{Delay: fixture.Duration(time.Second)}
+	fast := &fixture.Latency{Delay: fixture.Duration(time.Millisecond)}
+	assert.NoError(t, newServer(slow, nil))
+	assert.NoError(t, newServer(slow, &fixture.Latency{Delay: fixture.Duration(time.Second)}))
+
+	err := newServer(slow, fast)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "conflicting latency for /v1/prices")
+
+	// An explicit false conflicts with a prefix route too.
+	newPrefixServer := func(get, post *bool) error {
+		spec := &fixture.Spec{Services: map[fixture.ServiceID]*fixture.Service{
+			"crypto": {Paths: map[fixture.Path]fixture.PathItem{
+				"/v1/prices": {
+					"GET":  {Prefix: get, Example: &fixture.Step{Body: "ok"}},
+					"POST": {Prefix: post, Example: &fixture.Step{Body: "ok"}},
+				},
+			}},
+		}}
+		s := &StubServer{Spec: spec, Fixtures: &fixture.Fixtures{}}
+		return s.InitRouter()
+	}
+	yes, no := true, false
+	assert.NoError(t, newPrefixServer(&yes, nil))
+	err = newPrefixServer(&yes, &no)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "conflicting prefix for /v1/prices")
+}
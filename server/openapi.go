@@ -0,0 +1,287 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+// openAPIVersion is the OpenAPI version of exported documents.
+const openAPIVersion = "3.0.3"
+
+// OpenAPI describes every route of the server as an OpenAPI 3 document.
+// Response schemas are inferred from examples served from the fixtures, and
+// the x-finance-mock-service extension names the service of each path and
+// x-finance-mock-prefix marks prefix paths.
+func (s *StubServer) OpenAPI() map[string]interface{} {
+	f := s.store.current()
+
+	paths := make(map[string]interface{})
+	for _, pr := range s.routes().router.routes {
+		item := make(map[string]interface{})
+		for method, rt := range pr.methods {
+			item[strings.ToLower(method)] = s.openAPIOperation(rt, f)
+			item["x-finance-mock-service"] = rt.service
+		}
+		if pr.pattern.prefix {
+			item["x-finance-mock-prefix"] = true
+		}
+		paths[string(pr.path)] = item
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "finance-mock",
+			"version": Version,
+		},
+		"paths": paths,
+	}
+}
+
+func (s *StubServer) openAPIOperation(rt *route, f *fixture.Fixtures) map[string]interface{} {
+	params := []interface{}{}
+	for _, name := range pathParameterPattern.FindAllStringSubmatch(string(rt.path), -1) {
+		params = append(params, map[string]interface{}{
+			"name":     name[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range rt.params {
+		param := map[string]interface{}{
+			"name":     p.Name,
+			"in":       "query",
+			"required": p.Required,
+			"schema":   p.schema(),
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		if p.Type == fixture.ParamList {
+			param["style"] = "form"
+			param["explode"] = false
+		}
+		params = append(params, param)
+	}
+
+	response := map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	status := http.StatusOK
+	if example, ok := s.routeExample(rt, f); ok {
+		status = example.Status
+		response["description"] = http.StatusText(status)
+		if tree, err := jsonTree(example.Body); err == nil {
+			response["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema":  inferSchema(tree),
+					"example": tree,
+				},
+			}
+		}
+	}
+
+	op := map[string]interface{}{
+		"operationId": operationID(rt),
+		"parameters":  params,
+		"responses": map[string]interface{}{
+			strconv.Itoa(status): response,
+		},
+	}
+	if rt.operation.ResourceID != "" {
+		op["x-finance-mock-resource"] = rt.operation.ResourceID
+	}
+	return op
+}
+
+// operationID names an operation by its method and resource, or the last
+// literal segment of its path, e.g. getQuote.
+func operationID(rt *route) string {
+	name := string(rt.operation.ResourceID)
+	if name == "" {
+		for _, segment := range strings.Split(string(rt.path), "/") {
+			if segment != "" && !pathParameterPattern.MatchString(segment) {
+				name = segment
+			}
+		}
+	}
+	return strings.ToLower(rt.method) + strings.Title(name)
+}
+
+// routeExample serves a request for the first symbol of a route's fixtures,
+// or the operation's example when it has no resource.
+func (s *StubServer) routeExample(rt *route, f *fixture.Fixtures) (*fixture.Step, bool) {
+	op := rt.operation
+	if op.ResourceID == "" {
+		if op.Example == nil {
+			return nil, false
+		}
+		status, body := exampleResponse(op.Example)
+		return &fixture.Step{Status: status, Body: body}, true
+	}
+
+	tree, _ := f.Resources[rt.service][op.ResourceID].(map[string]interface{})
+	var symbols []string
+	for symbol := range tree {
+		if symbol != "error" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil, false
+	}
+	sort.Strings(symbols)
+	symbol := symbols[0]
+
+	// The symbol goes in the path parameters, the required string parameters
+	// or else after the path.
+	target := pathParameterPattern.ReplaceAllString(string(rt.path), url.PathEscape(symbol))
+	query := url.Values{}
+	for _, p := range rt.params {
+		if p.Required && (p.Type == "" || p.Type == fixture.ParamString || p.Type == fixture.ParamList) {
+			query.Set(p.Name, symbol)
+		}
+	}
+	if len(query) == 0 && target == string(rt.path) {
+		target += "/" + url.PathEscape(symbol)
+	}
+	target += "?" + query.Encode()
+
+	req, err := http.NewRequest(rt.method, target, nil)
+	if err != nil {
+		return nil, false
+	}
+	match := rt.match(req)
+	if match == nil {
+		return nil, false
+	}
+	params, perr := parseParams(rt.params, req.URL.Query(), false)
+	if perr != nil {
+		utils.Log(Verbose, "Couldn't build example request for %v: %v", rt.path, perr)
+		return nil, false
+	}
+	ctx := withMarket(withFixtures(req.Context(), f), Market)
+	req = req.WithContext(withParams(ctx, params))
+
+	h := *rt.handler
+	status, body := h.Handle(req, match)
+	if status != http.StatusOK {
+		return nil, false
+	}
+	return &fixture.Step{Status: status, Body: body}, true
+}
+
+// schema describes the values of a parameter.
+func (p *param) schema() map[string]interface{} {
+	schema := map[string]interface{}{}
+	values := schema
+	bounds := [2]string{"minLength", "maxLength"}
+	switch p.Type {
+	case fixture.ParamInt:
+		schema["type"] = "integer"
+		bounds = [2]string{"minimum", "maximum"}
+	case fixture.ParamEpoch:
+		schema["type"] = "integer"
+		schema["format"] = "unix-time"
+		bounds = [2]string{"minimum", "maximum"}
+	case fixture.ParamBool:
+		schema["type"] = "boolean"
+	case fixture.ParamList:
+		values = map[string]interface{}{"type": "string"}
+		schema["type"] = "array"
+		schema["items"] = values
+		bounds = [2]string{"minItems", "maxItems"}
+	default:
+		schema["type"] = "string"
+	}
+
+	if p.Min != nil {
+		schema[bounds[0]] = *p.Min
+	}
+	if p.Max != nil {
+		schema[bounds[1]] = *p.Max
+	}
+	if p.Pattern != "" {
+		values["pattern"] = p.Pattern
+	}
+	if len(p.Enum) > 0 {
+		enum := make([]interface{}, len(p.Enum))
+		for i, v := range p.Enum {
+			enum[i] = v
+		}
+		values["enum"] = enum
+	}
+	if p.Default != "" {
+		if v, err := p.coerce(p.Default); err == nil {
+			schema["default"] = v
+		}
+	}
+	return schema
+}
+
+// inferSchema describes a json value. Array items are described by the
+// union of their properties.
+func inferSchema(v interface{}) map[string]interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		props := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			props[k] = inferSchema(child)
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	case []interface{}:
+		var items map[string]interface{}
+		for _, child := range t {
+			items = mergeSchema(items, inferSchema(child))
+		}
+		if items == nil {
+			items = map[string]interface{}{}
+		}
+		return map[string]interface{}{"type": "array", "items": items}
+	case string:
+		return map[string]interface{}{"type": "string"}
+	case float64:
+		return map[string]interface{}{"type": "number"}
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+	}
+	return map[string]interface{}{"nullable": true}
+}
+
+// mergeSchema unites two inferred schemas. Objects take the properties of
+// both, and a null makes the other nullable.
+func mergeSchema(a, b map[string]interface{}) map[string]interface{} {
+	switch {
+	case len(a) == 0:
+		return b
+	case len(b) == 0:
+		return a
+	case isNullSchema(a):
+		b["nullable"] = true
+		return b
+	case isNullSchema(b):
+		a["nullable"] = true
+		return a
+	case a["type"] == "object" && b["type"] == "object":
+		props := a["properties"].(map[string]interface{})
+		for k, schema := range b["properties"].(map[string]interface{}) {
+			if existing, ok := props[k].(map[string]interface{}); ok {
+				props[k] = mergeSchema(existing, schema.(map[string]interface{}))
+			} else {
+				props[k] = schema
+			}
+		}
+	case a["type"] == "array" && b["type"] == "array":
+		a["items"] = mergeSchema(a["items"].(map[string]interface{}), b["items"].(map[string]interface{}))
+	}
+	return a
+}
+
+func isNullSchema(schema map[string]interface{}) bool {
+	return len(schema) == 1 && schema["nullable"] == true
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestExportOpenAPI(t *testing.T) {
+	s := newTestServer(t)
+
+	status, body := doConfigRequest(t, s, "GET", "/config/openapi.json", nil)
+	assert.Equal(t, http.StatusOK, status)
+	doc := body.(map[string]interface{})
+	assert.Equal(t, "3.0.3", doc["openapi"])
+
+	paths := doc["paths"].(map[string]interface{})
+	assert.Len(t, paths, 3)
+	quote := paths["/v7/finance/quote"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "getQuote", quote["operationId"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"name":        "symbols",
+		"in":          "query",
+		"description": "Specifies which symbols to provide quotes for.",
+		"required":    true,
+		"style":       "form",
+		"explode":     false,
+		"schema":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "minItems": 1.0},
+	}}, quote["parameters"])
+
+	// Responses are described by a real fixture.
+	content := quote["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})
+	media := content["application/json"].(map[string]interface{})
+	example := media["example"].(map[string]interface{})["quoteResponse"].(map[string]interface{})
+	assert.Len(t, example["result"], 1)
+	schema := media["schema"].(map[string]interface{})["properties"].(map[string]interface{})["quoteResponse"].(map[string]interface{})
+	result := schema["properties"].(map[string]interface{})["result"].(map[string]interface{})
+	props := result["items"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "number"}, props["regularMarketPrice"])
+	assert.Equal(t, map[string]interface{}{"type": "string"}, props["symbol"])
+}
+
+func TestExportOpenAPIRoundTrip(t *testing.T) {
+	spec, err := readTestSpec(t, "../fixture/spec.yml")
+	assert.NoError(t, err)
+	fixtures, err := readTestFixtures(t, "../fixture/resources.json")
+	assert.NoError(t, err)
+	var generic fixture.Spec
+	assert.NoError(t, yaml.Unmarshal([]byte(testGenericSpec), &generic))
+	spec.Services["crypto"] = generic.Services["crypto"]
+	s := &StubServer{Spec: spec, Fixtures: fixtures}
+	assert.NoError(t, s.InitRouter())
+
+	data, err := json.Marshal(s.OpenAPI())
+	assert.NoError(t, err)
+	spec, err = fixture.ParseOpenAPI(data)
+	assert.NoError(t, err)
+
+	// Paths come back to their own service.
+	assert.Len(t, spec.Services, 2)
+	assert.Len(t, spec.Services[fixture.ServiceYFin].Paths, 3)
+	for path, item := range generic.Services["crypto"].Paths {
+		imported := spec.Services["crypto"].Paths[path]["GET"]
+		assert.NotNil(t, imported, path)
+		assert.Equal(t, item["GET"].ResourceID, imported.ResourceID)
+	}
+
+	for path, item := range s.Spec.Services[fixture.ServiceYFin].Paths {
+		imported := spec.Services[fixture.ServiceYFin].Paths[path]["GET"]
+		assert.NotNil(t, imported, path)
+		assert.Equal(t, item["GET"].ResourceID, imported.ResourceID)
+		assert.Equal(t, item["GET"].Prefix, imported.Prefix, path)
+
+		// Untyped parameters come back as strings.
+		var params []*fixture.Parameter
+		for _, p := range item["GET"].Parameters {
+			p := *p
+			if p.Type == "" {
+				p.Type = fixture.ParamString
+			}
+			params = append(params, &p)
+		}
+		assert.Equal(t, params, imported.Parameters)
+	}
+}
+
+func TestInferSchema(t *testing.T) {
+	var v interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[{"a": 1, "b": null}, {"b": "x", "c": [true]}, null]`), &v))
+	assert.Equal(t, map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":     "object",
+			"nullable": true,
+			"properties": map[string]interface{}{
+				"a": map[string]interface{}{"type": "number"},
+				"b": map[string]interface{}{"type": "string", "nullable": true},
+				"c": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "boolean"}},
+			},
+		},
+	}, inferSchema(v))
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+	"github.com/piquette/finance-mock/yfin"
+)
+
+// globalParameters are taken by every endpoint, like the crumb yahoo hands
+// out to its sessions.
+var globalParameters = []string{"crumb"}
+
+// paramsKey is the context key of the typed parameters of a request.
+type paramsKey struct{}
+
+// withParams attaches the typed parameters of a request.
+func withParams(ctx context.Context, params map[string]interface{}) context.Context {
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+// paramsFrom gets the typed parameters of a request.
+func paramsFrom(ctx context.Context) map[string]interface{} {
+	params, _ := ctx.Value(paramsKey{}).(map[string]interface{})
+	return params
+}
+
+// requestParams gets the typed parameters of a request, read from its query
+// when the handler serving it is called directly. It errors when the
+// operation's parameters don't compile.
+func requestParams(r *http.Request, op *fixture.Operation) (map[string]interface{}, *paramError, error) {
+	if params := paramsFrom(r.Context()); params != nil {
+		return params, nil, nil
+	}
+
+	compiled, err := compileParams(op.Parameters)
+	if err != nil {
+		utils.Log(Verbose, "Couldn't compile parameters: %v", err)
+		return nil, nil, err
+	}
+	params, perr := parseParams(compiled, r.URL.Query(), false)
+	return params, perr, nil
+}
+
+// param is a spec parameter compiled for reading request values.
+type param struct {
+	*fixture.Parameter
+	pattern *regexp.Regexp
+}
+
+// paramError is a request parameter the spec doesn't allow.
+type paramError struct {
+	name    string
+	missing bool
+	unknown bool
+	reason  string
+}
+
+func (e *paramError) Error() string {
+	switch {
+	case e.missing:
+		return fmt.Sprintf("missing %v", e.name)
+	case e.unknown:
+		return fmt.Sprintf("unknown %v", e.name)
+	}
+	return fmt.Sprintf("invalid %v: %v", e.name, e.reason)
+}
+
+// response is the argument error naming the parameter.
+func (e *paramError) response() (int, *yfin.ErrorResponse) {
+	switch {
+	case e.missing:
+		return yfin.CreateMissingArgumentError(e.name)
+	case e.unknown:
+		return yfin.CreateUnknownArgumentError(e.name)
+	}
+	return yfin.CreateInvalidArgumentError(e.name, e.reason)
+}
+
+// compileParams checks the parameters of an operation and compiles their
+// patterns.
+func compileParams(params []*fixture.Parameter) ([]*param, error) {
+	compiled := make([]*param, 0, len(params))
+	for _, p := range params {
+		if p.Name == "" {
+			return nil, fmt.Errorf("parameter without a name")
+		}
+		switch p.Type {
+		case "", fixture.ParamString, fixture.ParamInt, fixture.ParamBool, fixture.ParamEpoch, fixture.ParamList:
+		default:
+			return nil, fmt.Errorf("unknown type of %v: %v", p.Name, p.Type)
+		}
+		if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+			return nil, fmt.Errorf("min of %v is above its max", p.Name)
+		}
+
+		c := &param{Parameter: p}
+		if p.Pattern != "" {
+			pattern, err := regexp.Compile(`\A(?:` + p.Pattern + `)\z`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern of %v: %v", p.Name, err)
+			}
+			c.pattern = pattern
+		}
+		if p.Default != "" {
+			if _, err := c.coerce(p.Default); err != nil {
+				return nil, fmt.Errorf("invalid default of %v: %v", p.Name, err)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// parseParams reads the query of a request into typed values. Required
+// parameters need a value, missing ones take their default and in strict
+// mode no others may be given. Parameters the spec doesn't list are kept as
+// strings.
+func parseParams(params []*param, query url.Values, strict bool) (map[string]interface{}, *paramError) {
+	data := make(map[string]interface{}, len(query))
+	known := append([]string{}, globalParameters...)
+	for _, p := range params {
+		known = append(known, p.Name)
+
+		raw := query.Get(p.Name)
+		if raw == "" {
+			if p.Required {
+				return nil, &paramError{name: p.Name, missing: true}
+			}
+			if p.Default == "" {
+				continue
+			}
+			raw = p.Default
+		}
+
+		v, err := p.coerce(raw)
+		if err != nil {
+			return nil, &paramError{name: p.Name, reason: err.Error()}
+		}
+		data[p.Name] = v
+	}
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := data[name]; ok {
+			continue
+		}
+		if strict && !utils.Contains(known, name) {
+			return nil, &paramError{name: name, unknown: true}
+		}
+		if v := query.Get(name); v != "" {
+			data[name] = v
+		}
+	}
+	return data, nil
+}
+
+// coerce reads a value as the parameter's type.
+func (p *param) coerce(raw string) (interface{}, error) {
+	values := []string{raw}
+	if p.Type == fixture.ParamList {
+		values = values[:0]
+		for _, v := range strings.Split(raw, ",") {
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	for _, v := range values {
+		if len(p.Enum) > 0 && !utils.Contains(p.Enum, v) {
+			return nil, fmt.Errorf("%v is not one of %v", v, strings.Join(p.Enum, ", "))
+		}
+		if p.pattern != nil && !p.pattern.MatchString(v) {
+			return nil, fmt.Errorf("%v doesn't match %v", v, p.Pattern)
+		}
+	}
+
+	switch p.Type {
+	case fixture.ParamInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not an integer", raw)
+		}
+		return n, p.checkBounds(float64(n), raw)
+	case fixture.ParamEpoch:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not a unix timestamp", raw)
+		}
+		return n, p.checkBounds(float64(n), raw)
+	case fixture.ParamBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not true or false", raw)
+		}
+		return b, nil
+	case fixture.ParamList:
+		return values, p.checkBounds(float64(len(values)), fmt.Sprintf("%d values", len(values)))
+	}
+	return raw, p.checkBounds(float64(len(raw)), fmt.Sprintf("length %d", len(raw)))
+}
+
+// checkBounds checks a number, the length of a string or the number of list
+// items against the parameter's min and max.
+func (p *param) checkBounds(n float64, desc string) error {
+	if p.Min != nil && n < *p.Min {
+		return fmt.Errorf("%v is below the minimum %v", desc, *p.Min)
+	}
+	if p.Max != nil && n > *p.Max {
+		return fmt.Errorf("%v is above the maximum %v", desc, *p.Max)
+	}
+	return nil
+}
+
+// listParam reads a list parameter. Specs without its type give it as a
+// comma separated string.
+func listParam(params map[string]interface{}, name string) []string {
+	switch v := params[name].(type) {
+	case []string:
+		return v
+	case string:
+		var values []string
+		for _, item := range strings.Split(v, ",") {
+			if item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// boolParam reads a bool parameter. Specs without its type give it as a
+// string.
+func boolParam(params map[string]interface{}, name string) bool {
+	switch v := params[name].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+func argumentError(body map[string]interface{}) interface{} {
+	envelope, _ := body["error"].(map[string]interface{})
+	return envelope["error"]
+}
+
+func TestValidateRequiredParams(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, target := range []string{"/v7/finance/quote", "/v7/finance/quote?symbols="} {
+		status, body := doRequest(t, s, "GET", target)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, map[string]interface{}{
+			"code":        "argument-error",
+			"description": `Missing value for the "symbols" argument`,
+		}, argumentError(body))
+	}
+
+	// Unknown parameters pass outside strict mode.
+	status, _ := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL&lang=en-US")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestValidateStrictParams(t *testing.T) {
+	s := newTestServer(t)
+	s.Strict = true
+
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL&lang=en-US")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, map[string]interface{}{
+		"code":        "argument-error",
+		"description": `Unrecognized argument "lang"`,
+	}, argumentError(body))
+
+	// Spec and global parameters pass.
+	status, _ = doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL&crumb=x")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = doRequest(t, s, "GET", "/v8/finance/chart/AAPL?interval=1d&includePrePost=true")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestParseTypedParams(t *testing.T) {
+	min, max := 1.0, 2.0
+	params, err := compileParams([]*fixture.Parameter{
+		{Name: "symbols", Type: fixture.ParamList, Pattern: `[A-Z]+`, Min: &min, Max: &max},
+		{Name: "period1", Type: fixture.ParamEpoch},
+		{Name: "count", Type: fixture.ParamInt, Min: &min},
+		{Name: "straddle", Type: fixture.ParamBool, Default: "false"},
+		{Name: "interval", Enum: []string{"1d", "1wk"}, Default: "1d"},
+	})
+	assert.NoError(t, err)
+
+	data, perr := parseParams(params, url.Values{
+		"symbols": {"AAPL,SPY"},
+		"period1": {"1526049000"},
+		"count":   {"3"},
+		"lang":    {"en-US"},
+	}, false)
+	assert.Nil(t, perr)
+	assert.Equal(t, map[string]interface{}{
+		"symbols":  []string{"AAPL", "SPY"},
+		"period1":  int64(1526049000),
+		"count":    3,
+		"straddle": false,
+		"interval": "1d",
+		"lang":     "en-US",
+	}, data)
+
+	testCases := []struct {
+		query url.Values
+		want  string
+	}{
+		{url.Values{"symbols": {"AAPL,SPY,O"}}, "invalid symbols: 3 values is above the maximum 2"},
+		{url.Values{"symbols": {"aapl"}}, "invalid symbols: aapl doesn't match [A-Z]+"},
+		{url.Values{"period1": {"yesterday"}}, "invalid period1: yesterday is not a unix timestamp"},
+		{url.Values{"count": {"0"}}, "invalid count: 0 is below the minimum 1"},
+		{url.Values{"straddle": {"yes"}}, "invalid straddle: yes is not true or false"},
+		{url.Values{"interval": {"2d"}}, "invalid interval: 2d is not one of 1d, 1wk"},
+	}
+	for _, tc := range testCases {
+		_, perr := parseParams(params, tc.query, false)
+		assert.NotNil(t, perr)
+		assert.Equal(t, tc.want, perr.Error())
+	}
+}
+
+func TestCompileParamsErrors(t *testing.T) {
+	min, max := 2.0, 1.0
+	for _, p := range []*fixture.Parameter{
+		{Name: "a", Type: "float"},
+		{Name: "a", Pattern: "("},
+		{Name: "a", Min: &min, Max: &max},
+		{Name: "a", Type: fixture.ParamInt, Default: "one"},
+		{Name: "a", Enum: []string{"x"}, Default: "y"},
+	} {
+		_, err := compileParams([]*fixture.Parameter{p})
+		assert.Error(t, err)
+	}
+}
+
+func TestInvalidParamValues(t *testing.T) {
+	s := newTestServer(t)
+
+	status, body := doRequest(t, s, "GET", "/v8/finance/chart/AAPL?interval=2d")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, map[string]interface{}{
+		"code":        "argument-error",
+		"description": `Invalid value for the "interval" argument: 2d is not one of 1m, 2m, 5m, 15m, 30m, 60m, 90m, 1h, 1d, 5d, 1wk, 1mo, 3mo`,
+	}, argumentError(body))
+
+	status, _ = doRequest(t, s, "GET", "/v7/finance/options/AMD?straddle=maybe")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestOptionsStraddleParam(t *testing.T) {
+	s := newTestServer(t)
+
+	format := func(target string) interface{} {
+		status, body := doRequest(t, s, "GET", target)
+		assert.Equal(t, http.StatusOK, status)
+		result := body["optionChain"].(map[string]interface{})["result"].([]interface{})
+		options := result[0].(map[string]interface{})["options"].([]interface{})
+		expiration := options[0].(map[string]interface{})
+		_, straddles := expiration["straddles"]
+		return straddles
+	}
+	assert.Equal(t, false, format("/v7/finance/options/AMD"))
+	assert.Equal(t, true, format("/v7/finance/options/AMD?straddle=true"))
+	assert.Equal(t, true, format("/v7/finance/options/AMD?straddle=1"))
+}
+
+func TestUntypedParams(t *testing.T) {
+	spec, err := readTestSpec(t, "../fixture/spec.yml")
+	assert.NoError(t, err)
+	fixtures, err := readTestFixtures(t, "../fixture/resources.json")
+	assert.NoError(t, err)
+	for _, item := range spec.Services[fixture.ServiceYFin].Paths {
+		for _, op := range item {
+			for _, p := range op.Parameters {
+				p.Type = ""
+			}
+		}
+	}
+	s := &StubServer{Spec: spec, Fixtures: fixtures}
+	assert.NoError(t, s.InitRouter())
+
+	// Specs written before typed parameters still serve lists and flags.
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL,SPY")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body["quoteResponse"].(map[string]interface{})["result"], 2)
+
+	status, body = doRequest(t, s, "GET", "/v7/finance/options/AMD?straddle=true")
+	assert.Equal(t, http.StatusOK, status)
+	result := body["optionChain"].(map[string]interface{})["result"].([]interface{})
+	options := result[0].(map[string]interface{})["options"].([]interface{})
+	_, straddles := options[0].(map[string]interface{})["straddles"]
+	assert.True(t, straddles)
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+	"github.com/piquette/finance-mock/yfin"
+)
+
+// rateLimiter throttles clients per route with token buckets.
+type rateLimiter struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	routes map[fixture.Path]*routeLimit
+}
+
+// routeLimit holds the buckets of one route.
+type routeLimit struct {
+	config  fixture.RateLimit
+	buckets map[string]*bucket
+}
+
+// bucket is the token bucket of one client.
+type bucket struct {
+	Tokens  float64   `json:"tokens"`
+	Allowed int       `json:"allowed"`
+	Limited int       `json:"limited"`
+	Updated time.Time `json:"updated"`
+}
+
+// rateLimitState is the config and bucket state of a route.
+type rateLimitState struct {
+	Config  fixture.RateLimit  `json:"config"`
+	Buckets map[string]*bucket `json:"buckets"`
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{now: time.Now, routes: make(map[fixture.Path]*routeLimit)}
+}
+
+// set configures the rate limit of a route and resets its buckets.
+func (l *rateLimiter) set(path fixture.Path, config *fixture.RateLimit) error {
+	c := *config
+	if c.Rate <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	if c.Burst <= 0 {
+		c.Burst = int(math.Ceil(c.Rate))
+	}
+	switch {
+	case c.Key == "":
+		c.Key = fixture.RateLimitByIP
+	case c.Key == fixture.RateLimitByIP, c.Key == fixture.RateLimitByCrumb:
+	case strings.HasPrefix(c.Key, fixture.RateLimitByHeaderPrefix) && len(c.Key) > len(fixture.RateLimitByHeaderPrefix):
+	default:
+		return fmt.Errorf("unknown rate limit key: %v", c.Key)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.routes[path] = &routeLimit{config: c, buckets: make(map[string]*bucket)}
+	return nil
+}
+
+// clear removes the rate limit of a route.
+func (l *rateLimiter) clear(path fixture.Path) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.routes, path)
+}
+
+// state lists the config and buckets of every limited route.
+func (l *rateLimiter) state() map[fixture.Path]*rateLimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	state := make(map[fixture.Path]*rateLimitState)
+	for path, rl := range l.routes {
+		buckets := make(map[string]*bucket)
+		for key, b := range rl.buckets {
+			c := *b
+			c.Tokens = rl.refill(b, now)
+			buckets[key] = &c
+		}
+		state[path] = &rateLimitState{Config: rl.config, Buckets: buckets}
+	}
+	return state
+}
+
+// take spends a token of the requesting client. If none is left it returns
+// how long until the next one.
+func (l *rateLimiter) take(path fixture.Path, r *http.Request) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rl := l.routes[path]
+	if rl == nil {
+		rl = l.routes[allRoutes]
+	}
+	if rl == nil {
+		return true, 0
+	}
+
+	now := l.now()
+	key := rl.key(r)
+	b := rl.buckets[key]
+	if b == nil {
+		b = &bucket{Tokens: float64(rl.config.Burst), Updated: now}
+		rl.buckets[key] = b
+	}
+	b.Tokens = rl.refill(b, now)
+	b.Updated = now
+
+	if b.Tokens < 1 {
+		b.Limited++
+		wait := (1 - b.Tokens) / rl.config.Rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	b.Tokens--
+	b.Allowed++
+	return true, 0
+}
+
+func (rl *routeLimit) refill(b *bucket, now time.Time) float64 {
+	tokens := b.Tokens + now.Sub(b.Updated).Seconds()*rl.config.Rate
+	return math.Min(tokens, float64(rl.config.Burst))
+}
+
+// key identifies the client a request is counted against.
+func (rl *routeLimit) key(r *http.Request) string {
+	switch {
+	case rl.config.Key == fixture.RateLimitByCrumb:
+		return r.URL.Query().Get("crumb")
+	case strings.HasPrefix(rl.config.Key, fixture.RateLimitByHeaderPrefix):
+		return r.Header.Get(strings.TrimPrefix(rl.config.Key, fixture.RateLimitByHeaderPrefix))
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeTooManyRequests answers a throttled request.
+func (s *StubServer) writeTooManyRequests(w http.ResponseWriter, r *http.Request, start time.Time, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	utils.Log(Verbose, "Rate limited request, retry after %vs", retryAfter)
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	status, body := yfin.CreateTooManyRequests()
+	s.writeRawResponse(w, r, start, status, "text/html;charset=utf-8", body)
+}
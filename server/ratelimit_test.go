@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRateLimitTake(t *testing.T) {
+	now := time.Unix(1531612800, 0)
+	l := newRateLimiter()
+	l.now = func() time.Time { return now }
+
+	assert.NoError(t, l.set("/v7/finance/quote", &fixture.RateLimit{Rate: 1, Burst: 2}))
+
+	req := httptest.NewRequest("GET", "/v7/finance/quote", nil)
+	ok, _ := l.take("/v7/finance/quote", req)
+	assert.True(t, ok)
+	ok, _ = l.take("/v7/finance/quote", req)
+	assert.True(t, ok)
+	ok, wait := l.take("/v7/finance/quote", req)
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	// Other clients have their own bucket.
+	other := httptest.NewRequest("GET", "/v7/finance/quote", nil)
+	other.RemoteAddr = "10.0.0.1:1234"
+	ok, _ = l.take("/v7/finance/quote", other)
+	assert.True(t, ok)
+
+	// Tokens refill over time.
+	now = now.Add(1500 * time.Millisecond)
+	ok, _ = l.take("/v7/finance/quote", req)
+	assert.True(t, ok)
+
+	state := l.state()["/v7/finance/quote"]
+	b := state.Buckets["192.0.2.1"]
+	assert.Equal(t, 3, b.Allowed)
+	assert.Equal(t, 1, b.Limited)
+	assert.InDelta(t, 0.5, b.Tokens, 0.001)
+
+	assert.Error(t, l.set("/a", &fixture.RateLimit{}))
+	assert.Error(t, l.set("/a", &fixture.RateLimit{Rate: 1, Key: "cookie"}))
+}
+
+func TestRateLimitByHeader(t *testing.T) {
+	s := newTestServer(t)
+
+	status, _ := doConfigRequest(t, s, "POST", "/config/ratelimit", url.Values{
+		"path": {"/v7/finance/quote"},
+		"rate": {"0.1"},
+		"key":  {"header:X-Client"},
+	})
+	assert.Equal(t, http.StatusOK, status)
+
+	req := httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL", nil)
+	req.Header.Set("X-Client", "a")
+	w := httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+	assert.Equal(t, "Too Many Requests\r\n", w.Body.String())
+
+	req.Header.Set("X-Client", "b")
+	w = httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	status, body := doConfigRequest(t, s, "GET", "/config/ratelimit", nil)
+	assert.Equal(t, http.StatusOK, status)
+	buckets := body.(map[string]interface{})["/v7/finance/quote"].(map[string]interface{})["buckets"].(map[string]interface{})
+	assert.Equal(t, 1.0, buckets["a"].(map[string]interface{})["limited"])
+}
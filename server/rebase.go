@@ -0,0 +1,307 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+const (
+	// minEpoch and maxEpoch bound the numbers taken for epoch seconds.
+	minEpoch = 946684800  // 2000-01-01
+	maxEpoch = 4102444800 // 2100-01-01
+
+	// marketZone is where trading days start and end.
+	marketZone = "America/New_York"
+)
+
+var (
+	// epochKeys name the fields holding epoch seconds.
+	epochKeys = regexp.MustCompile(`(Time|Date|Dates|Timestamp|TimestampStart|TimestampEnd)$|^(timestamp|start|end|expiration)$`)
+
+	// calendarKeys name the epoch fields of calendar events, which keep their
+	// weekday, e.g. option expirations on fridays.
+	calendarKeys = regexp.MustCompile(`^(expiration|expirationDate|expirationDates|expireDate|dividendDate|earningsTimestamp|earningsTimestampStart|earningsTimestampEnd)$`)
+)
+
+// timeRebaser shifts the epoch fields of responses from the date the fixtures
+// were captured onto the latest trading day. Each resource of a symbol was
+// captured on its own date, found by captureTime, unless a date is given. Market times
+// move by whole trading days and keep their time of day in New York, so they
+// stay within the same trading hours. Calendar events move by whole weeks, at
+// least as far. Holidays count as trading days.
+type timeRebaser struct {
+	mu       sync.Mutex
+	zone     *time.Location
+	captures map[captureKey]time.Time
+	latest   time.Time
+	fixed    bool
+}
+
+// captureKey is a symbol in a resource.
+type captureKey struct {
+	resource fixture.ResourceID
+	symbol   string
+}
+
+// rebaseOffset is the shift of responses served on one day.
+type rebaseOffset struct {
+	tradingDays int
+	weeks       int
+}
+
+// newTimeRebaser rebases from a capture date, YYYY-MM-DD, or if empty from
+// the dates found in fixtures. It needs the market's time zone from the zone
+// database of the host.
+func newTimeRebaser(captureDate string, f *fixture.Fixtures) (*timeRebaser, error) {
+	zone, err := time.LoadLocation(marketZone)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load the market time zone: %v", err)
+	}
+
+	r := &timeRebaser{zone: zone}
+	if captureDate == "" {
+		r.detect(f)
+		return r, nil
+	}
+
+	capture, err := time.ParseInLocation("2006-01-02", captureDate, zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture date: %v", captureDate)
+	}
+	r.latest = capture
+	r.fixed = true
+	return r, nil
+}
+
+// detect finds the capture date of every symbol of every resource in
+// fixtures, unless the date was given.
+func (r *timeRebaser) detect(f *fixture.Fixtures) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fixed {
+		return
+	}
+
+	r.captures = make(map[captureKey]time.Time)
+	r.latest = time.Time{}
+	for resource, tree := range f.Resources[fixture.ServiceYFin] {
+		symbols, _ := tree.(map[string]interface{})
+		for symbol, node := range symbols {
+			t := captureTime(resource, node)
+			if t == 0 {
+				continue
+			}
+			capture := tradingDate(time.Unix(int64(t), 0), r.zone)
+			r.captures[captureKey{resource, symbol}] = capture
+			if capture.After(r.latest) {
+				r.latest = capture
+			}
+		}
+	}
+	utils.Log(Verbose, "Rebasing times of %v fixture(s) captured until %v", len(r.captures), r.latest.Format("2006-01-02"))
+}
+
+// captureTime finds when the fixture of a symbol was captured: the last bar
+// of a chart, or the start of its regular trading period, and the latest
+// regularMarketTime of quotes and options.
+func captureTime(resource fixture.ResourceID, node interface{}) float64 {
+	if resource != fixture.YFinChart {
+		return latestMarketTime(node)
+	}
+
+	chart, _ := node.(map[string]interface{})
+	var last float64
+	timestamps, _ := chart["timestamp"].([]interface{})
+	for _, v := range timestamps {
+		if t, ok := v.(float64); ok && t > last {
+			last = t
+		}
+	}
+	if last > 0 {
+		return last
+	}
+
+	meta, _ := chart["meta"].(map[string]interface{})
+	periods, _ := meta["currentTradingPeriod"].(map[string]interface{})
+	regular, _ := periods["regular"].(map[string]interface{})
+	start, _ := regular["start"].(float64)
+	return start
+}
+
+// latestMarketTime finds the latest regularMarketTime in a fixture tree.
+func latestMarketTime(node interface{}) float64 {
+	var latest float64
+	switch t := node.(type) {
+	case map[string]interface{}:
+		for k, v := range t {
+			if f, ok := v.(float64); ok && k == "regularMarketTime" && f > latest {
+				latest = f
+			}
+			if f := latestMarketTime(v); f > latest {
+				latest = f
+			}
+		}
+	case []interface{}:
+		for _, v := range t {
+			if f := latestMarketTime(v); f > latest {
+				latest = f
+			}
+		}
+	}
+	return latest
+}
+
+// capture gets the capture date of a symbol in a resource, or of the latest
+// fixture.
+func (r *timeRebaser) capture(resource fixture.ResourceID, symbol string) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if capture, ok := r.captures[captureKey{resource, symbol}]; ok {
+		return capture
+	}
+	return r.latest
+}
+
+// rebaseOffsetFor gets the shift from a capture date onto the latest trading
+// day as of now, with days starting in zone.
+func rebaseOffsetFor(capture, now time.Time, zone *time.Location) rebaseOffset {
+	if capture.IsZero() {
+		return rebaseOffset{}
+	}
+	from := lastWeekday(capture)
+	to := lastWeekday(tradingDate(now, zone))
+	if !to.After(from) {
+		return rebaseOffset{}
+	}
+
+	days := calendarDays(from, to)
+	return rebaseOffset{
+		tradingDays: weekdaysBetween(from, to),
+		weeks:       (days + 6) / 7,
+	}
+}
+
+// rebaseTimes shifts the epoch fields of a response from the capture dates of
+// the resource served. Objects with a symbol field shift from the capture
+// date of their symbol, others from that of the requested symbol.
+func (s *StubServer) rebaseTimes(r *http.Request, rt *route, data interface{}) interface{} {
+	tree, err := jsonTree(data)
+	if err != nil {
+		return data
+	}
+
+	var symbol string
+	if symbols := requestSymbols(r, rt); len(symbols) > 0 {
+		symbol = symbols[0]
+	}
+	w := &rebaseWalker{rebaser: s.rebase, resource: rt.operation.ResourceID, now: time.Now()}
+	return w.apply(tree, "", w.offset(symbol))
+}
+
+// rebaseWalker shifts the epoch fields of a tree served from a resource.
+type rebaseWalker struct {
+	rebaser  *timeRebaser
+	resource fixture.ResourceID
+	now      time.Time
+}
+
+func (w *rebaseWalker) offset(symbol string) rebaseOffset {
+	return rebaseOffsetFor(w.rebaser.capture(w.resource, symbol), w.now, w.rebaser.zone)
+}
+
+func (w *rebaseWalker) apply(node interface{}, key string, o rebaseOffset) interface{} {
+	switch t := node.(type) {
+	case map[string]interface{}:
+		if symbol, ok := t["symbol"].(string); ok {
+			o = w.offset(symbol)
+		}
+		for k, v := range t {
+			t[k] = w.apply(v, k, o)
+		}
+	case []interface{}:
+		for i, v := range t {
+			t[i] = w.apply(v, key, o)
+		}
+	case float64:
+		return o.shift(t, key, w.rebaser.zone)
+	}
+	return node
+}
+
+// shift moves a number if it is an epoch field, keeping market times within
+// their trading day in zone.
+func (o rebaseOffset) shift(v float64, key string, zone *time.Location) float64 {
+	if o.tradingDays == 0 && o.weeks == 0 {
+		return v
+	}
+	if v < minEpoch || v > maxEpoch || v != math.Trunc(v) || !epochKeys.MatchString(key) {
+		return v
+	}
+	ts := time.Unix(int64(v), 0).In(zone)
+	if calendarKeys.MatchString(key) {
+		return float64(ts.AddDate(0, 0, 7*o.weeks).Unix())
+	}
+	return float64(addTradingDays(ts, o.tradingDays, zone).Unix())
+}
+
+// tradingDate is the midnight in zone starting the day of t.
+func tradingDate(t time.Time, zone *time.Location) time.Time {
+	t = t.In(zone)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, zone)
+}
+
+// lastWeekday moves weekend dates back to friday.
+func lastWeekday(d time.Time) time.Time {
+	switch d.Weekday() {
+	case time.Saturday:
+		return d.AddDate(0, 0, -1)
+	case time.Sunday:
+		return d.AddDate(0, 0, -2)
+	}
+	return d
+}
+
+func calendarDays(from, to time.Time) int {
+	// Round across daylight saving changes.
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+// weekdaysBetween counts the weekdays after from up to and including to.
+func weekdaysBetween(from, to time.Time) int {
+	days := calendarDays(from, to)
+	n := days / 7 * 5
+	wd := int(from.Weekday())
+	for i := 0; i < days%7; i++ {
+		wd = (wd + 1) % 7
+		if wd != int(time.Saturday) && wd != int(time.Sunday) {
+			n++
+		}
+	}
+	return n
+}
+
+// addTradingDays moves t by n weekdays, keeping its time of day in zone.
+// Weekend times move with the friday before them.
+func addTradingDays(t time.Time, n int, zone *time.Location) time.Time {
+	t = t.In(zone)
+	day := tradingDate(t, zone)
+	weekday := lastWeekday(day)
+
+	shifted := weekday.AddDate(0, 0, n/5*7)
+	for rem := n % 5; rem > 0; rem-- {
+		shifted = shifted.AddDate(0, 0, 1)
+		for shifted.Weekday() == time.Saturday || shifted.Weekday() == time.Sunday {
+			shifted = shifted.AddDate(0, 0, 1)
+		}
+	}
+	shifted = shifted.AddDate(0, 0, calendarDays(weekday, day))
+
+	return time.Date(shifted.Year(), shifted.Month(), shifted.Day(), t.Hour(), t.Minute(), t.Second(), 0, zone)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+// newYork is the market's time zone in tests.
+func newYork() *time.Location {
+	zone, err := time.LoadLocation(marketZone)
+	if err != nil {
+		panic(err)
+	}
+	return zone
+}
+
+func nyTime(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, newYork())
+}
+
+func TestTradingDays(t *testing.T) {
+	thursday := nyTime(2018, 5, 10, 0, 0)
+	assert.Equal(t, 1, weekdaysBetween(thursday, nyTime(2018, 5, 11, 0, 0)))
+	assert.Equal(t, 2, weekdaysBetween(thursday, nyTime(2018, 5, 14, 0, 0)))
+	assert.Equal(t, 7, weekdaysBetween(thursday, nyTime(2018, 5, 21, 0, 0)))
+
+	assert.Equal(t, nyTime(2018, 5, 14, 15, 59), addTradingDays(nyTime(2018, 5, 10, 15, 59), 2, newYork()))
+	assert.Equal(t, nyTime(2018, 5, 21, 9, 30), addTradingDays(nyTime(2018, 5, 10, 9, 30), 7, newYork()))
+
+	// Times of day are kept across daylight saving changes.
+	assert.Equal(t, nyTime(2018, 3, 12, 9, 30), addTradingDays(nyTime(2018, 3, 9, 9, 30), 1, newYork()))
+
+	// Weekend times move with the friday before them.
+	assert.Equal(t, nyTime(2018, 5, 15, 12, 0), addTradingDays(nyTime(2018, 5, 12, 12, 0), 1, newYork()))
+}
+
+func TestRebaseOffset(t *testing.T) {
+	capture := nyTime(2018, 5, 10, 0, 0)
+	assert.Equal(t, rebaseOffset{tradingDays: 2, weeks: 1}, rebaseOffsetFor(capture, nyTime(2018, 5, 14, 10, 0), newYork()))
+
+	// Weekends serve friday.
+	assert.Equal(t, rebaseOffset{tradingDays: 6, weeks: 2}, rebaseOffsetFor(capture, nyTime(2018, 5, 19, 10, 0), newYork()))
+
+	assert.Equal(t, rebaseOffset{}, rebaseOffsetFor(capture, nyTime(2018, 5, 10, 18, 0), newYork()))
+	assert.Equal(t, rebaseOffset{}, rebaseOffsetFor(time.Time{}, nyTime(2018, 5, 14, 10, 0), newYork()))
+
+	_, err := newTimeRebaser("10/05/2018", nil)
+	assert.Error(t, err)
+}
+
+func TestRebaseFields(t *testing.T) {
+	r, err := newTimeRebaser("2018-05-10", nil)
+	assert.NoError(t, err)
+	w := &rebaseWalker{rebaser: r, now: nyTime(2018, 5, 14, 10, 0)}
+
+	tree := decodeJSON(t, `{
+		"regularMarketTime": 1525982402,
+		"regularMarketVolume": 1525982402,
+		"timestamp": [1525959000, 1525962600],
+		"currentTradingPeriod": {"regular": {"start": 1525959000, "end": 1525982400}},
+		"expirationDates": [1526601600]
+	}`)
+	rebased := w.apply(tree, "", w.offset("")).(map[string]interface{})
+	day := 4 * 24 * 60 * 60.0
+	assert.Equal(t, 1525982402+day, rebased["regularMarketTime"])
+	assert.Equal(t, 1525982402.0, rebased["regularMarketVolume"])
+	assert.Equal(t, []interface{}{1525959000 + day, 1525962600 + day}, rebased["timestamp"])
+	assert.Equal(t, 1525959000+day, rebased["currentTradingPeriod"].(map[string]interface{})["regular"].(map[string]interface{})["start"])
+	assert.Equal(t, []interface{}{1526601600 + 7*24*60*60.0}, rebased["expirationDates"])
+}
+
+func TestRebaseTime(t *testing.T) {
+	s := newTestServer(t)
+	s.RebaseTime = true
+	assert.NoError(t, s.InitRouter())
+
+	// Symbols and resources captured on different dates all move onto today.
+	assert.Equal(t, "2018-05-11", s.rebase.capture(fixture.YFinQuotes, "AAPL").Format("2006-01-02"))
+	assert.Equal(t, "2018-07-13", s.rebase.capture(fixture.YFinQuotes, "O=F").Format("2006-01-02"))
+	assert.Equal(t, "2018-05-29", s.rebase.capture(fixture.YFinChart, "AAPL").Format("2006-01-02"))
+	assert.Equal(t, "2018-07-13", s.rebase.capture(fixture.YFinOptions, "AMD").Format("2006-01-02"))
+
+	Market = MarketStateRegular
+	defer func() { Market = MarketStatePost }()
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL,O=F")
+	assert.Equal(t, http.StatusOK, status)
+	today := lastWeekday(tradingDate(time.Now(), s.rebase.zone))
+	for _, q := range body["quoteResponse"].(map[string]interface{})["result"].([]interface{}) {
+		quote := q.(map[string]interface{})
+		marketTime := time.Unix(int64(quote["regularMarketTime"].(float64)), 0)
+		assert.Equal(t, today, tradingDate(marketTime, s.rebase.zone), quote["symbol"])
+	}
+
+	// Chart bars stay on weekdays, the last one today.
+	status, body = doRequest(t, s, "GET", "/v8/finance/chart/AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	chart := body["chart"].(map[string]interface{})["result"].([]interface{})[0].(map[string]interface{})
+	timestamps := chart["timestamp"].([]interface{})
+	for _, ts := range timestamps {
+		day := time.Unix(int64(ts.(float64)), 0).In(s.rebase.zone).Weekday()
+		assert.NotEqual(t, time.Saturday, day)
+		assert.NotEqual(t, time.Sunday, day)
+	}
+	last := time.Unix(int64(timestamps[len(timestamps)-1].(float64)), 0)
+	assert.Equal(t, today, tradingDate(last, s.rebase.zone))
+	periods := chart["meta"].(map[string]interface{})["currentTradingPeriod"].(map[string]interface{})
+	start := time.Unix(int64(periods["regular"].(map[string]interface{})["start"].(float64)), 0)
+	assert.Equal(t, today, tradingDate(start, s.rebase.zone))
+
+	// So do options.
+	status, body = doRequest(t, s, "GET", "/v7/finance/options/AMD")
+	assert.Equal(t, http.StatusOK, status)
+	options := body["optionChain"].(map[string]interface{})["result"].([]interface{})[0].(map[string]interface{})
+	quote := options["quote"].(map[string]interface{})
+	assert.Equal(t, today, tradingDate(time.Unix(int64(quote["regularMarketTime"].(float64)), 0), s.rebase.zone))
+}
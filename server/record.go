@@ -0,0 +1,260 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+	"github.com/piquette/finance-mock/yfin"
+)
+
+// DefaultRecordFile is where recorded fixtures are written by default.
+const DefaultRecordFile = "recorded.json"
+
+// recorder forwards the requests the fixtures can't serve to an upstream and
+// captures the responses into the fixtures. It keeps what it captured, to
+// apply again over fixtures that are reloaded or reset.
+type recorder struct {
+	mu       sync.Mutex
+	upstream *upstream
+	file     string
+	recorded map[string]time.Time
+	captures map[string]*capturedNode
+}
+
+// capturedNode is a fixture node recorded from the upstream.
+type capturedNode struct {
+	path  []string
+	value interface{}
+}
+
+// recordingInfo reports a recording.
+type recordingInfo struct {
+	Upstream string               `json:"upstream"`
+	File     string               `json:"file"`
+	Recorded map[string]time.Time `json:"recorded"`
+}
+
+func newRecorder(base, file string) (*recorder, error) {
+	u, err := newUpstream(base)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" {
+		file = DefaultRecordFile
+	}
+	return &recorder{
+		upstream: u,
+		file:     file,
+		recorded: make(map[string]time.Time),
+		captures: make(map[string]*capturedNode),
+	}, nil
+}
+
+// keep notes a recorded fixture node.
+func (rec *recorder) keep(path []string, v interface{}) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	key := strings.Join(path, "/")
+	rec.recorded[key] = time.Now()
+	rec.captures[key] = &capturedNode{path: path, value: v}
+}
+
+// replay writes the recorded nodes over the fixtures of a store. Nodes the
+// fixtures no longer take are left out.
+func (rec *recorder) replay(store *fixtureStore) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	keys := make([]string, 0, len(rec.captures))
+	for key := range rec.captures {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		c := rec.captures[key]
+		_, err := store.update(c.path, func(interface{}, bool) (interface{}, error) {
+			return c.value, nil
+		})
+		if err != nil {
+			utils.Log(true, "Couldn't replay recorded fixture %v: %v", key, err)
+		}
+	}
+}
+
+// replayRecording applies what was recorded over the shared fixtures after
+// they are reloaded or reset.
+func (s *StubServer) replayRecording() {
+	if s.recorder != nil {
+		s.recorder.replay(s.store)
+	}
+}
+
+func (rec *recorder) info() *recordingInfo {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	recorded := make(map[string]time.Time, len(rec.recorded))
+	for k, v := range rec.recorded {
+		recorded[k] = v
+	}
+	return &recordingInfo{Upstream: rec.upstream.base.String(), File: rec.file, Recorded: recorded}
+}
+
+// missingFixtures reports whether the fixtures serving a request lack any of
+// the symbols it asks for.
+func (s *StubServer) missingFixtures(r *http.Request, rt *route, symbols []string) bool {
+	f := fixturesFrom(r.Context())
+	if f == nil || len(symbols) == 0 {
+		return false
+	}
+
+	y := &YFinService{Resources: f.Resources[fixture.ServiceYFin]}
+	tree, _ := y.Resources[rt.operation.ResourceID].(map[string]interface{})
+	market := strings.ToUpper(string(marketFrom(r.Context())))
+
+	for _, symbol := range symbols {
+		node, _ := tree[symbol].(map[string]interface{})
+
+		switch rt.operation.ResourceID {
+		case fixture.YFinQuotes:
+			if node[market] != nil {
+				continue
+			}
+			if _, contract := y.optionContract(symbol); contract != nil {
+				continue
+			}
+		case fixture.YFinChart:
+			if node != nil {
+				continue
+			}
+			if _, contract := y.optionContract(symbol); contract != nil {
+				continue
+			}
+		case fixture.YFinOptions:
+			if node[optionsFormat(r)] != nil {
+				continue
+			}
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// record serves a request from the upstream and captures a successful
+// response into the shared fixtures.
+func (s *StubServer) record(w http.ResponseWriter, r *http.Request, start time.Time, rt *route, symbols []string) {
+	utils.Log(Verbose, "Recording from upstream: %v %v", r.Method, r.URL.String())
+
+	resp, data, err := s.recorder.upstream.forward(r)
+	if err != nil {
+		utils.Log(Verbose, "Couldn't reach upstream: %v", err)
+		status, body := yfin.CreateServerError(http.StatusBadGateway)
+		s.writeResponse(w, r, start, status, body)
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		err = s.capture(r, rt, symbols, data)
+		if err != nil {
+			utils.Log(Verbose, "Couldn't record response: %v", err)
+		}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json;charset=utf-8"
+	}
+	s.writeRawResponse(w, r, start, resp.StatusCode, contentType, data)
+}
+
+// capture stores an upstream response in the fixtures layout: quotes by
+// symbol and market state, charts by symbol and options by symbol and format.
+func (s *StubServer) capture(r *http.Request, rt *route, symbols []string, data []byte) error {
+	var envelope map[string]struct {
+		Result []interface{} `json:"result"`
+	}
+	err := json.Unmarshal(data, &envelope)
+	if err != nil {
+		return err
+	}
+
+	var results []interface{}
+	for _, v := range envelope {
+		results = v.Result
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("response has no results")
+	}
+
+	service := string(fixture.ServiceYFin)
+	resource := string(rt.operation.ResourceID)
+	store := func(path []string, v interface{}) error {
+		path = append([]string{service, resource}, path...)
+		_, err := s.store.update(path, func(interface{}, bool) (interface{}, error) {
+			return v, nil
+		})
+		if err == nil {
+			s.recorder.keep(path, v)
+		}
+		return err
+	}
+
+	switch rt.operation.ResourceID {
+	case fixture.YFinQuotes:
+		market := strings.ToUpper(string(marketFrom(r.Context())))
+		for _, v := range results {
+			quote, _ := v.(map[string]interface{})
+			symbol, _ := quote["symbol"].(string)
+			if symbol == "" {
+				continue
+			}
+			err = store([]string{symbol, market}, quote)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case fixture.YFinChart:
+		return store([]string{symbols[0]}, results[0])
+	case fixture.YFinOptions:
+		return store([]string{symbols[0], optionsFormat(r)}, results[0])
+	}
+	return fmt.Errorf("can't record %v", resource)
+}
+
+// SaveRecording writes the fixtures with everything recorded so far to the
+// record file. It does nothing when not recording.
+func (s *StubServer) SaveRecording() error {
+	if s.recorder == nil {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.store.current(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	utils.Log(Verbose, "Writing %v recorded fixtures to %v", len(s.recorder.recorded), s.recorder.file)
+	return ioutil.WriteFile(s.recorder.file, append(data, '\n'), 0644)
+}
+
+// optionsFormat is the options fixture a request reads.
+func optionsFormat(r *http.Request) string {
+	if boolParam(paramsFrom(r.Context()), "straddle") {
+		return "straddle"
+	}
+	return "chain"
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+func newRecordingServer(t *testing.T, upstream *httptest.Server, file string) *StubServer {
+	s := newTestServer(t)
+	s.Record = upstream.URL
+	s.RecordFile = file
+	assert.NoError(t, s.InitRouter())
+	return s
+}
+
+func TestRecordMissingFixtures(t *testing.T) {
+	var forwarded []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r.URL.RequestURI())
+		assert.Empty(t, r.Header.Get(SessionHeader))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v7/finance/quote":
+			w.Write([]byte(`{"quoteResponse": {"result": [{"symbol": "ZZZZ", "regularMarketPrice": 42}], "error": null}}`))
+		case "/v8/finance/chart/ZZZZ":
+			w.Write([]byte(`{"chart": {"result": [{"meta": {"symbol": "ZZZZ"}}], "error": null}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"chart": {"result": null, "error": {"code": "Not Found"}}}`))
+		}
+	}))
+	defer upstream.Close()
+
+	dir, err := ioutil.TempDir("", "finance-mock")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "recorded.json")
+	s := newRecordingServer(t, upstream, file)
+
+	// Fixtures are served without asking the upstream.
+	status, _ := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, forwarded)
+
+	// Missing symbols are recorded, then served from the fixtures.
+	for i := 0; i < 2; i++ {
+		status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=ZZZZ")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 42.0, quotePrice(t, body))
+	}
+	assert.Equal(t, []string{"/v7/finance/quote?symbols=ZZZZ"}, forwarded)
+
+	status, _ = doRequest(t, s, "GET", "/v8/finance/chart/ZZZZ")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Upstream errors are passed through and not recorded.
+	status, _ = doRequest(t, s, "GET", "/v8/finance/chart/NOPE")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = doRequest(t, s, "GET", "/v8/finance/chart/NOPE")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Len(t, forwarded, 4)
+
+	status, body := doConfigRequest(t, s, "POST", "/config/record", nil)
+	assert.Equal(t, http.StatusOK, status)
+	recorded := body.(map[string]interface{})["recorded"].(map[string]interface{})
+	assert.Contains(t, recorded, "yfin/quote/ZZZZ/POST")
+	assert.Contains(t, recorded, "yfin/chart/ZZZZ")
+	assert.Len(t, recorded, 2)
+
+	// The file holds the loaded and the recorded fixtures.
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	var f fixture.Fixtures
+	assert.NoError(t, json.Unmarshal(data, &f))
+	quotes := f.Resources[fixture.ServiceYFin][fixture.YFinQuotes].(map[string]interface{})
+	assert.Contains(t, quotes, "AAPL")
+	assert.Contains(t, quotes, "ZZZZ")
+}
+
+func TestRecordSurvivesReload(t *testing.T) {
+	var forwarded int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"quoteResponse": {"result": [{"symbol": "ZZZZ", "regularMarketPrice": 42}], "error": null}}`))
+	}))
+	defer upstream.Close()
+
+	dir, err := ioutil.TempDir("", "finance-mock")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "recorded.json")
+	s := newRecordingServer(t, upstream, file)
+
+	status, _ := doRequest(t, s, "GET", "/v7/finance/quote?symbols=ZZZZ")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, forwarded)
+
+	// Recordings are applied again over reloaded and reset fixtures.
+	assert.NoError(t, s.Reload(s.Spec, s.Fixtures))
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=ZZZZ")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 42.0, quotePrice(t, body))
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/fixtures", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = doRequest(t, s, "GET", "/v7/finance/quote?symbols=ZZZZ")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, forwarded)
+
+	assert.NoError(t, s.SaveRecording())
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	var f fixture.Fixtures
+	assert.NoError(t, json.Unmarshal(data, &f))
+	quotes := f.Resources[fixture.ServiceYFin][fixture.YFinQuotes].(map[string]interface{})
+	assert.Contains(t, quotes, "AAPL")
+	assert.Contains(t, quotes, "ZZZZ")
+}
+
+func TestRecordUnreachableUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	s := newRecordingServer(t, upstream, "")
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=ZZZZ")
+	assert.Equal(t, http.StatusBadGateway, status)
+	assert.NotNil(t, body["error"])
+}
+
+func TestRecordConfigWithoutRecording(t *testing.T) {
+	s := newTestServer(t)
+	status, _ := doConfigRequest(t, s, "GET", "/config/record", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	s.Record = "ftp://example.com"
+	assert.Error(t, s.InitRouter())
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+// errNotWatching is returned when reloading without watching files.
+var errNotWatching = fmt.Errorf("not watching spec and fixtures")
+
+// Loader loads the spec and fixtures to serve.
+type Loader func() (*fixture.Spec, *fixture.Fixtures, error)
+
+// reloader tracks the reloads of the spec and fixtures.
+type reloader struct {
+	mu     sync.Mutex
+	load   Loader
+	status reloadStatus
+}
+
+// reloadStatus reports the reloads. A failed reload keeps serving the last
+// good spec and fixtures.
+type reloadStatus struct {
+	Watching   []string   `json:"watching"`
+	Reloads    int        `json:"reloads"`
+	LastReload *time.Time `json:"lastReload,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	ErrorTime  *time.Time `json:"errorTime,omitempty"`
+}
+
+// Reload swaps in a spec and fixtures. Requests in flight finish on the old
+// ones. Routes, settings, scenarios and stubs are rebuilt from the new spec,
+// with the changes made through /config applied over them, while fixture
+// overrides are dropped, in sessions too. Recorded fixtures are kept. On
+// error nothing changes.
+func (s *StubServer) Reload(spec *fixture.Spec, fixtures *fixture.Fixtures) error {
+
+	// Check everything on a scratch server first.
+	scratch := &StubServer{Spec: spec, Fixtures: fixtures}
+	err := scratch.InitRouter()
+	if err != nil {
+		return err
+	}
+	for service, resources := range fixtures.Resources {
+		if _, ok := spec.Services[service]; !ok {
+			continue
+		}
+		for resource, tree := range resources {
+			err := scratch.validateResource(service, resource, tree)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	err = s.loadSpec(spec, fixtures)
+	if err != nil {
+		return err
+	}
+	s.store.load(fixtures)
+	s.replayRecording()
+	s.sessions.reload(s.store.current())
+	if s.rebase != nil {
+		s.rebase.detect(fixtures)
+	}
+	return nil
+}
+
+// Watch polls paths for changes every interval and reloads the spec and
+// fixtures with load when they change, until stop is closed. Directories are
+// watched recursively.
+func (s *StubServer) Watch(paths []string, interval time.Duration, load Loader, stop <-chan struct{}) {
+	s.reload.mu.Lock()
+	s.reload.load = load
+	s.reload.status.Watching = paths
+	s.reload.mu.Unlock()
+
+	last := fingerprint(paths)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := fingerprint(paths)
+		if current == last {
+			continue
+		}
+		last = current
+
+		utils.Log(Verbose, "Change detected in %v", strings.Join(paths, ", "))
+		s.reloadNow()
+	}
+}
+
+// reloadNow reloads with the watch loader and records the outcome.
+func (s *StubServer) reloadNow() error {
+	s.reload.mu.Lock()
+	load := s.reload.load
+	s.reload.mu.Unlock()
+	if load == nil {
+		return errNotWatching
+	}
+
+	spec, fixtures, err := load()
+	if err == nil {
+		err = s.Reload(spec, fixtures)
+	}
+
+	now := time.Now()
+	s.reload.mu.Lock()
+	defer s.reload.mu.Unlock()
+	if err != nil {
+		utils.Log(true, "Reload failed, keeping the previous spec and fixtures: %v", err)
+		s.reload.status.Error = err.Error()
+		s.reload.status.ErrorTime = &now
+		return err
+	}
+
+	utils.Log(true, "Reloaded spec and fixtures")
+	s.reload.status.Reloads++
+	s.reload.status.LastReload = &now
+	s.reload.status.Error = ""
+	s.reload.status.ErrorTime = nil
+	return nil
+}
+
+func (r *reloader) info() reloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// fingerprint sums up the names, sizes and modification times of the files
+// below paths.
+func fingerprint(paths []string) string {
+	var b strings.Builder
+	for _, p := range paths {
+		filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				fmt.Fprintf(&b, "%v: %v\n", path, err)
+				return nil
+			}
+			if !info.IsDir() {
+				fmt.Fprintf(&b, "%v %v %v\n", path, info.Size(), info.ModTime().UnixNano())
+			}
+			return nil
+		})
+	}
+	return b.String()
+}
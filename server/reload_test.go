@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// readTestSpec reads a spec. It returns errors rather than failing the test,
+// so the watch goroutine can call it.
+func readTestSpec(t *testing.T, path string) (*fixture.Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec fixture.Spec
+	return &spec, yaml.Unmarshal(data, &spec)
+}
+
+func readTestFixtures(t *testing.T, path string) (*fixture.Fixtures, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures fixture.Fixtures
+	return &fixtures, json.Unmarshal(data, &fixtures)
+}
+
+// setTestPrice sets the regular market price of AAPL in fixtures.
+func setTestPrice(f *fixture.Fixtures, price float64) {
+	quotes := f.Resources[fixture.ServiceYFin][fixture.YFinQuotes].(map[string]interface{})
+	quotes["AAPL"].(map[string]interface{})["POST"].(map[string]interface{})["regularMarketPrice"] = price
+}
+
+func TestReload(t *testing.T) {
+	s := newTestServer(t)
+	before := s.store.current()
+
+	spec, err := readTestSpec(t, "../fixture/spec.yml")
+	assert.NoError(t, err)
+	fixtures, err := readTestFixtures(t, "../fixture/resources.json")
+	assert.NoError(t, err)
+	setTestPrice(fixtures, 1.5)
+
+	assert.NoError(t, s.Reload(spec, fixtures))
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1.5, quotePrice(t, body))
+
+	// Requests that started earlier keep their snapshot.
+	assert.NotEqual(t, before, s.store.current())
+	assert.NotEqual(t, 1.5, before.Resources[fixture.ServiceYFin][fixture.YFinQuotes].(map[string]interface{})["AAPL"].(map[string]interface{})["POST"].(map[string]interface{})["regularMarketPrice"])
+
+	// Invalid specs and fixtures are rejected, keeping the current ones.
+	broken, err := readTestSpec(t, "../fixture/spec.yml")
+	assert.NoError(t, err)
+	broken.Services[fixture.ServiceYFin].Paths["/v7/finance/quote"]["GET"].Latency = &fixture.Latency{Distribution: "bogus"}
+	assert.Error(t, s.Reload(broken, fixtures))
+
+	brokenFixtures, err := readTestFixtures(t, "../fixture/resources.json")
+	assert.NoError(t, err)
+	brokenFixtures.Resources[fixture.ServiceYFin][fixture.YFinQuotes] = []interface{}{}
+	assert.Error(t, s.Reload(spec, brokenFixtures))
+
+	status, body = doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1.5, quotePrice(t, body))
+	assert.Empty(t, s.routes().latency.config())
+}
+
+func TestReloadRebuildsSettings(t *testing.T) {
+	spec, err := readTestSpec(t, "../fixture/spec.yml")
+	assert.NoError(t, err)
+	fixtures, err := readTestFixtures(t, "../fixture/resources.json")
+	assert.NoError(t, err)
+	spec.Services[fixture.ServiceYFin].Paths["/v7/finance/quote"]["GET"].Latency = &fixture.Latency{Delay: fixture.Duration(time.Millisecond)}
+	spec.Stubs = []*fixture.Stub{{Name: "ping", Request: &fixture.StubRequest{Path: "/v1/ping"}, Response: &fixture.Step{Body: "pong"}}}
+
+	s := &StubServer{Spec: spec, Fixtures: fixtures}
+	assert.NoError(t, s.InitRouter())
+	status, _ := doConfigRequest(t, s, "POST", "/config/latency", url.Values{"path": {"/v8/finance/chart"}, "delay": {"2ms"}})
+	assert.Equal(t, http.StatusOK, status)
+	_, body := doRequest(t, s, "GET", "/session/a/v7/finance/quote?symbols=AAPL")
+	price := quotePrice(t, body)
+
+	// Settings removed from the spec are gone, runtime ones stay.
+	spec, err = readTestSpec(t, "../fixture/spec.yml")
+	assert.NoError(t, err)
+	fixtures, err = readTestFixtures(t, "../fixture/resources.json")
+	assert.NoError(t, err)
+	setTestPrice(fixtures, 1.5)
+	assert.NoError(t, s.Reload(spec, fixtures))
+
+	latency := s.routes().latency.config()
+	assert.Len(t, latency, 1)
+	assert.Equal(t, fixture.Duration(2*time.Millisecond), latency["/v8/finance/chart"].Delay)
+	assert.Empty(t, s.routes().stubs.list())
+	status, _ = doRequest(t, s, "GET", "/v1/ping")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Sessions see the reloaded fixtures.
+	assert.NotEqual(t, 1.5, price)
+	_, body = doRequest(t, s, "GET", "/session/a/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, 1.5, quotePrice(t, body))
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "finance-mock")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	specPath := filepath.Join(dir, "spec.yml")
+	fixturesPath := filepath.Join(dir, "resources.json")
+
+	data, err := ioutil.ReadFile("../fixture/spec.yml")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(specPath, data, 0644))
+	data, err = ioutil.ReadFile("../fixture/resources.json")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(fixturesPath, data, 0644))
+
+	load := func() (*fixture.Spec, *fixture.Fixtures, error) {
+		spec, err := readTestSpec(t, specPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		fixtures, err := readTestFixtures(t, fixturesPath)
+		return spec, fixtures, err
+	}
+
+	s := newTestServer(t)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Watch([]string{specPath, fixturesPath}, 5*time.Millisecond, load, stop)
+	}()
+	// Stop watching before the temp dir is removed.
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	eventually := func(cond func() bool) {
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal("condition not met in time")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// Wait for the watch to take its first look.
+	eventually(func() bool { return len(s.reload.info().Watching) == 2 })
+	time.Sleep(20 * time.Millisecond)
+
+	fixtures, err := readTestFixtures(t, fixturesPath)
+	assert.NoError(t, err)
+	setTestPrice(fixtures, 1.5)
+	data, err = json.Marshal(fixtures)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(fixturesPath, data, 0644))
+
+	eventually(func() bool {
+		_, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+		return quotePrice(t, body) == 1.5
+	})
+	assert.Equal(t, 1, s.reload.info().Reloads)
+
+	// Parse errors keep the last good state and are reported.
+	assert.NoError(t, ioutil.WriteFile(fixturesPath, []byte(`{"resources": `), 0644))
+	eventually(func() bool { return s.reload.info().Error != "" })
+
+	_, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, 1.5, quotePrice(t, body))
+
+	status, info := doConfigRequest(t, s, "GET", "/config/reload", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, strings.Contains(info.(map[string]interface{})["error"].(string), "unexpected end"))
+
+	status, _ = doConfigRequest(t, s, "POST", "/config/reload", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+}
+
+func TestReloadWithoutWatching(t *testing.T) {
+	s := newTestServer(t)
+	status, _ := doConfigRequest(t, s, "POST", "/config/reload", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/piquette/finance-mock/fixture"
+)
+
+// RouteMatch is the spec route a request matched. Params holds the named path
+// parameters and, when the route matched as a prefix, Rest holds the escaped
+// path segments after it.
+type RouteMatch struct {
+	Path      fixture.Path
+	Method    string
+	Operation *fixture.Operation
+	Params    map[string]string
+	Rest      string
+}
+
+// symbol is the symbol named by the path of a request: its symbol parameter
+// or else the last segment after the route.
+func (m *RouteMatch) symbol() string {
+	if symbol := m.Params["symbol"]; symbol != "" {
+		return symbol
+	}
+	if m.Rest == "" {
+		return ""
+	}
+	symbol, err := url.PathUnescape(path.Base(m.Rest))
+	if err != nil {
+		return ""
+	}
+	return symbol
+}
+
+// pathPattern is a spec path split into its segments. Segments written as
+// {name} are parameters matching any one segment. A prefix pattern also
+// matches the paths extending it.
+type pathPattern struct {
+	path     fixture.Path
+	segments []string
+	params   []string
+	prefix   bool
+}
+
+func newPathPattern(p fixture.Path, prefix bool) *pathPattern {
+	pattern := &pathPattern{path: p, segments: splitPath(string(p)), prefix: prefix}
+	pattern.params = make([]string, len(pattern.segments))
+	for i, segment := range pattern.segments {
+		if m := pathParameterPattern.FindStringSubmatch(segment); m != nil && m[0] == segment {
+			pattern.params[i] = m[1]
+		}
+	}
+	return pattern
+}
+
+// match matches an escaped request path against the pattern exactly or, for
+// prefix patterns, as a prefix ending at a segment boundary. It returns the
+// unescaped parameters and the escaped segments after the pattern, empty when
+// the match is exact.
+func (p *pathPattern) match(escapedPath string) (map[string]string, string, bool) {
+	segments := splitPath(escapedPath)
+	if len(segments) < len(p.segments) || (!p.prefix && len(segments) > len(p.segments)) {
+		return nil, "", false
+	}
+
+	params := make(map[string]string)
+	for i, literal := range p.segments {
+		segment, err := url.PathUnescape(segments[i])
+		if err != nil || segment == "" {
+			return nil, "", false
+		}
+		if name := p.params[i]; name != "" {
+			params[name] = segment
+		} else if segment != literal {
+			return nil, "", false
+		}
+	}
+	return params, strings.Join(segments[len(p.segments):], "/"), true
+}
+
+// moreSpecific orders patterns by specificity: longer paths first, then
+// literal segments before parameters from the left, then by path.
+func (p *pathPattern) moreSpecific(q *pathPattern) bool {
+	if len(p.segments) != len(q.segments) {
+		return len(p.segments) > len(q.segments)
+	}
+	for i := range p.segments {
+		pParam, qParam := p.params[i] != "", q.params[i] != ""
+		if pParam != qParam {
+			return qParam
+		}
+	}
+	return p.path < q.path
+}
+
+// splitPath splits a path into its segments, ignoring the slashes around it.
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// router routes request paths to spec paths. Its routes are ordered by
+// specificity, so matching doesn't depend on the order of the spec.
+type router struct {
+	routes []*pathRoute
+}
+
+func newRouter(routes []*pathRoute) *router {
+	sorted := append([]*pathRoute{}, routes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].pattern.moreSpecific(sorted[j].pattern)
+	})
+	return &router{routes: sorted}
+}
+
+// match finds the route of an escaped request path. A path matching a route
+// exactly takes it over the prefix routes it extends, and otherwise takes the
+// most specific prefix route it extends by whole segments.
+func (rr *router) match(escapedPath string) (*pathRoute, map[string]string, string) {
+	var prefix *pathRoute
+	var prefixParams map[string]string
+	var prefixRest string
+	for _, pr := range rr.routes {
+		params, rest, ok := pr.pattern.match(escapedPath)
+		if !ok {
+			continue
+		}
+		if rest == "" {
+			return pr, params, ""
+		}
+		if prefix == nil {
+			prefix, prefixParams, prefixRest = pr, params, rest
+		}
+	}
+	return prefix, prefixParams, prefixRest
+}
+
+// routeMiss is a route close to a path no route matches.
+type routeMiss struct {
+	Path     fixture.Path `json:"path"`
+	Methods  []string     `json:"methods"`
+	Distance int          `json:"distance"`
+}
+
+// routeNotFound is the response to a request no route matches.
+type routeNotFound struct {
+	Error      string       `json:"error"`
+	NearMisses []*routeMiss `json:"nearMisses,omitempty"`
+}
+
+// notFound describes an unrouted request and the routes it nearly matched.
+func (rr *router) notFound(r *http.Request) *routeNotFound {
+	return &routeNotFound{
+		Error:      fmt.Sprintf(invalidRoute, r.Method, r.URL.Path),
+		NearMisses: rr.nearMisses(r.URL.EscapedPath()),
+	}
+}
+
+// nearMisses lists the routes within an edit distance of half their length
+// from a path, closest first and no further than twice the closest.
+// Parameters take the path's segment in their place, and the path is cut to
+// the length of the route so that it may extend it. Paths extending an exact
+// route are near misses of it too.
+func (rr *router) nearMisses(escapedPath string) []*routeMiss {
+	segments := splitPath(escapedPath)
+	var misses []*routeMiss
+	for _, pr := range rr.routes {
+		want := make([]string, len(pr.pattern.segments))
+		copy(want, pr.pattern.segments)
+		for i, name := range pr.pattern.params {
+			if name != "" && i < len(segments) {
+				want[i] = segments[i]
+			}
+		}
+		got := segments
+		if len(got) > len(want) {
+			got = got[:len(want)]
+		}
+
+		route := "/" + strings.Join(want, "/")
+		distance := editDistance("/"+strings.Join(got, "/"), route)
+		if distance > len(route)/2 {
+			continue
+		}
+		misses = append(misses, &routeMiss{Path: pr.path, Methods: pr.allow, Distance: distance})
+	}
+
+	sort.SliceStable(misses, func(i, j int) bool {
+		if misses[i].Distance != misses[j].Distance {
+			return misses[i].Distance < misses[j].Distance
+		}
+		return misses[i].Path < misses[j].Path
+	})
+	for i, miss := range misses {
+		if i == maxNearMisses || miss.Distance > 2*misses[0].Distance {
+			return misses[:i]
+		}
+	}
+	return misses
+}
+
+// editDistance counts the single character edits turning one string into
+// another.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRouterSpecificity(t *testing.T) {
+	paths := map[fixture.Path]bool{
+		"/v7/finance":                 true,
+		"/v7/finance/quote":           false,
+		"/v7/finance/quote/{symbol}":  true,
+		"/v7/finance/quote/summary":   false,
+		"/v7/{version}/quote/summary": false,
+		"/v8/finance/chart":           true,
+	}
+	var sorted []fixture.Path
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+
+	testCases := []struct {
+		target string
+		want   fixture.Path
+		params map[string]string
+		rest   string
+	}{
+		{"/v7/finance/quote", "/v7/finance/quote", map[string]string{}, ""},
+		{"/v7/finance/quote/", "/v7/finance/quote", map[string]string{}, ""},
+		{"/v7/finance/quoteSummary", "/v7/finance", map[string]string{}, "quoteSummary"},
+		{"/v7/finance/quote/summary", "/v7/finance/quote/summary", map[string]string{}, ""},
+		{"/v7/finance/quote/AAPL", "/v7/finance/quote/{symbol}", map[string]string{"symbol": "AAPL"}, ""},
+		{"/v7/finance/quote/EUR%2FUSD/x", "/v7/finance/quote/{symbol}", map[string]string{"symbol": "EUR/USD"}, "x"},
+		{"/v7/other/quote/summary", "/v7/{version}/quote/summary", map[string]string{"version": "other"}, ""},
+		{"/v8/finance/chart/EUR%2FUSD", "/v8/finance/chart", map[string]string{}, "EUR%2FUSD"},
+		{"/v7/finance/quote/summary/x", "/v7/finance/quote/{symbol}", map[string]string{"symbol": "summary"}, "x"},
+		{"/v7/finance/chart/x", "/v7/finance", map[string]string{}, "chart/x"},
+	}
+
+	// Matching doesn't depend on the order of the spec.
+	for i := 0; i < 20; i++ {
+		var routes []*pathRoute
+		for _, j := range rand.Perm(len(sorted)) {
+			path := sorted[j]
+			routes = append(routes, &pathRoute{path: path, pattern: newPathPattern(path, paths[path])})
+		}
+		rr := newRouter(routes)
+
+		for _, tc := range testCases {
+			pr, params, rest := rr.match(tc.target)
+			assert.NotNil(t, pr, tc.target)
+			assert.Equal(t, tc.want, pr.path, tc.target)
+			assert.Equal(t, tc.params, params, tc.target)
+			assert.Equal(t, tc.rest, rest, tc.target)
+		}
+
+		pr, _, _ := rr.match("/v9/finance")
+		assert.Nil(t, pr)
+
+		// Other paths only match themselves.
+		pr, _, _ = rr.match("/v7/other/quote/summary/x")
+		assert.Nil(t, pr)
+	}
+}
+
+func TestRouteNotFound(t *testing.T) {
+	s := newTestServer(t)
+
+	// Routes only extend by whole segments.
+	status, body := doRequest(t, s, "GET", "/v7/finance/quoteSummary?symbols=AAPL")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "Unrecognized request URL (GET: /v7/finance/quoteSummary).", body["error"])
+	misses := body["nearMisses"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"path":     "/v7/finance/quote",
+		"methods":  []interface{}{"GET", "HEAD", "OPTIONS"},
+		"distance": float64(7),
+	}, misses[0])
+
+	status, body = doRequest(t, s, "GET", "/v8/finance/chrt/AAPL")
+	assert.Equal(t, http.StatusNotFound, status)
+	misses = body["nearMisses"].([]interface{})
+	assert.Equal(t, "/v8/finance/chart", misses[0].(map[string]interface{})["path"])
+	assert.Equal(t, float64(1), misses[0].(map[string]interface{})["distance"])
+	assert.Len(t, misses, 1)
+
+	// Paths not marked as prefixes don't take the paths extending them.
+	status, body = doRequest(t, s, "GET", "/v7/finance/quote/x?symbols=AAPL")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "Unrecognized request URL (GET: /v7/finance/quote/x).", body["error"])
+	misses = body["nearMisses"].([]interface{})
+	assert.Equal(t, "/v7/finance/quote", misses[0].(map[string]interface{})["path"])
+	assert.Equal(t, float64(0), misses[0].(map[string]interface{})["distance"])
+
+	status, body = doRequest(t, s, "GET", "/unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Nil(t, body["nearMisses"])
+}
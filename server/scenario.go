@@ -0,0 +1,192 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+	"github.com/piquette/finance-mock/yfin"
+)
+
+// scenarioEngine plays scripted responses, keeping a position per session.
+type scenarioEngine struct {
+	mu        sync.Mutex
+	scenarios []*scenarioState
+}
+
+// scenarioState is a scenario and the calls made by each session.
+type scenarioState struct {
+	scenario *fixture.Scenario
+	calls    map[string]int
+}
+
+// scenarioStatus reports a scenario and its progress.
+type scenarioStatus struct {
+	*fixture.Scenario
+	Calls map[string]int `json:"calls"`
+}
+
+func newScenarioEngine() *scenarioEngine {
+	return &scenarioEngine{}
+}
+
+// add adds a scenario, replacing and rewinding one with the same name.
+func (e *scenarioEngine) add(scenario *fixture.Scenario) error {
+	if scenario.Name == "" {
+		return fmt.Errorf("scenario needs a name")
+	}
+	if len(scenario.Steps) == 0 {
+		return fmt.Errorf("scenario %v has no steps", scenario.Name)
+	}
+	steps := make([]*fixture.Step, len(scenario.Steps))
+	for i, step := range scenario.Steps {
+		if step.Repeat < 0 {
+			return fmt.Errorf("scenario %v step %v repeats a negative number of times", scenario.Name, i)
+		}
+		if step.Status != 0 && http.StatusText(step.Status) == "" {
+			return fmt.Errorf("scenario %v step %v has an unknown status: %v", scenario.Name, i, step.Status)
+		}
+		steps[i] = normalizeStep(step)
+	}
+
+	// Play a copy, as the scenarios of the spec are added again on reload
+	// while requests may be serving them.
+	copied := *scenario
+	copied.Steps = steps
+	scenario = &copied
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state := &scenarioState{scenario: scenario, calls: make(map[string]int)}
+	for i, s := range e.scenarios {
+		if s.scenario.Name == scenario.Name {
+			e.scenarios[i] = state
+			return nil
+		}
+	}
+	e.scenarios = append(e.scenarios, state)
+	return nil
+}
+
+// normalizeStep copies a step with its body made json encodable.
+func normalizeStep(step *fixture.Step) *fixture.Step {
+	normalized := *step
+	normalized.Body = utils.NormalizeYAML(step.Body)
+	return &normalized
+}
+
+// remove removes a scenario, or all of them if the name is empty.
+func (e *scenarioEngine) remove(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if name == "" {
+		e.scenarios = nil
+		return
+	}
+	for i, s := range e.scenarios {
+		if s.scenario.Name == name {
+			e.scenarios = append(e.scenarios[:i], e.scenarios[i+1:]...)
+			return
+		}
+	}
+}
+
+// status lists the scenarios and the calls made by each session.
+func (e *scenarioEngine) status() []*scenarioStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := []*scenarioStatus{}
+	for _, s := range e.scenarios {
+		calls := make(map[string]int, len(s.calls))
+		for session, n := range s.calls {
+			calls[session] = n
+		}
+		status = append(status, &scenarioStatus{Scenario: s.scenario, Calls: calls})
+	}
+	return status
+}
+
+// next advances the first scenario matching the request in the session and
+// returns its step, or nil once the scenario is used up.
+func (e *scenarioEngine) next(path fixture.Path, symbols []string, session string) *fixture.Step {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, s := range e.scenarios {
+		if !s.matches(path, symbols) {
+			continue
+		}
+
+		call := s.calls[session]
+		step := s.step(call)
+		if step == nil {
+			continue
+		}
+		s.calls[session] = call + 1
+		return step
+	}
+	return nil
+}
+
+func (s *scenarioState) matches(path fixture.Path, symbols []string) bool {
+	if s.scenario.Path != path {
+		return false
+	}
+	return s.scenario.Symbol == "" || utils.Contains(symbols, s.scenario.Symbol)
+}
+
+// step finds the step serving a call.
+func (s *scenarioState) step(call int) *fixture.Step {
+	for _, step := range s.scenario.Steps {
+		repeat := step.Repeat
+		if repeat == 0 {
+			repeat = 1
+		}
+		if call < repeat {
+			return step
+		}
+		call -= repeat
+	}
+	return nil
+}
+
+// writeStep answers a request with a scripted step. It returns false if the
+// step is to be served from fixtures.
+func (s *StubServer) writeStep(w http.ResponseWriter, r *http.Request, start time.Time, step *fixture.Step) bool {
+	status := step.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if step.Body == nil && status < 300 {
+		return false
+	}
+
+	utils.Log(Verbose, "Playing scenario step: status=%v", status)
+	for k, v := range step.Headers {
+		w.Header().Set(k, v)
+	}
+
+	if body, ok := step.Body.(string); ok {
+		contentType := w.Header().Get("Content-Type")
+		if contentType == "" {
+			contentType = "text/html;charset=utf-8"
+		}
+		s.writeRawResponse(w, r, start, status, contentType, []byte(body))
+		return true
+	}
+
+	if step.Body == nil && status >= 500 {
+		status, data := yfin.CreateServerError(status)
+		s.writeResponse(w, r, start, status, data)
+		return true
+	}
+
+	s.writeResponse(w, r, start, status, step.Body)
+	return true
+}
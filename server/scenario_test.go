@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func quotePrice(t *testing.T, body map[string]interface{}) interface{} {
+	quotes := body["quoteResponse"].(map[string]interface{})["result"].([]interface{})
+	return quotes[0].(map[string]interface{})["regularMarketPrice"]
+}
+
+func TestScenarioSteps(t *testing.T) {
+	s := newTestServer(t)
+
+	stale := map[string]interface{}{
+		"quoteResponse": map[string]interface{}{
+			"result": []interface{}{map[string]interface{}{"symbol": "AAPL", "regularMarketPrice": 1.5}},
+			"error":  nil,
+		},
+	}
+	assert.NoError(t, s.routes().addScenario(&fixture.Scenario{
+		Name:   "flaky",
+		Path:   "/v7/finance/quote",
+		Symbol: "AAPL",
+		Steps: []*fixture.Step{
+			{Status: http.StatusServiceUnavailable},
+			{Body: stale, Repeat: 2},
+		},
+	}))
+
+	get := func(session, symbols string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("GET", "/v7/finance/quote?symbols="+symbols, nil)
+		req.Header.Set(SessionHeader, session)
+		w := httptest.NewRecorder()
+		s.HandleRequest(w, req)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	// Other symbols are not scripted.
+	status, _ := get("a", "SPY")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body := get("a", "AAPL")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.NotNil(t, body["error"])
+
+	// Sessions advance on their own.
+	status, _ = get("b", "AAPL")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	for i := 0; i < 2; i++ {
+		status, body = get("a", "AAPL")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 1.5, quotePrice(t, body))
+	}
+
+	// Then back to fixtures.
+	status, body = get("a", "AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEqual(t, 1.5, quotePrice(t, body))
+
+	scenarios := s.routes().scenarios.status()
+	assert.Equal(t, map[string]int{"a": 3, "b": 1}, scenarios[0].Calls)
+}
+
+func TestScenarioReload(t *testing.T) {
+	s := newTestServer(t)
+	var scenario fixture.Scenario
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+name: stale
+path: /v7/finance/quote
+steps:
+- repeat: 1000000
+  body: {quoteResponse: {result: [{symbol: AAPL, regularMarketPrice: 1.5}], error: null}}
+`), &scenario))
+	s.Scenarios = []*fixture.Scenario{&scenario}
+	assert.NoError(t, s.InitRouter())
+
+	// Reloads add the scenario again while requests play it.
+	reloaded := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 5 && err == nil; i++ {
+			err = s.Reload(s.Spec, s.Fixtures)
+		}
+		reloaded <- err
+	}()
+	for i := 0; i < 50; i++ {
+		status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 1.5, quotePrice(t, body))
+	}
+	assert.NoError(t, <-reloaded)
+
+	// The scenario given is left alone.
+	assert.IsType(t, map[interface{}]interface{}{}, scenario.Steps[0].Body)
+}
+
+func TestScenarioConfig(t *testing.T) {
+	s := newTestServer(t)
+
+	scenario := []byte(`{"name": "down", "path": "/v8/finance/chart", "steps": [{"status": 502, "body": "<html>bad gateway</html>"}]}`)
+	req := httptest.NewRequest("POST", "/config/scenarios", bytes.NewReader(scenario))
+	w := httptest.NewRecorder()
+	s.HandleConfigRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/v8/finance/chart/AAPL", nil)
+	w = httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "<html>bad gateway</html>", w.Body.String())
+
+	status, _ := doRequest(t, s, "GET", "/v8/finance/chart/AAPL")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Unknown paths are rejected.
+	req = httptest.NewRequest("POST", "/config/scenarios", bytes.NewReader([]byte(`{"name": "x", "path": "/nope", "steps": [{}]}`)))
+	w = httptest.NewRecorder()
+	s.HandleConfigRequest(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/scenarios?name=down", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, s.routes().scenarios.status())
+}
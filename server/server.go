@@ -2,9 +2,14 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/piquette/finance-mock/fixture"
@@ -13,6 +18,10 @@ import (
 
 const invalidRoute = "Unrecognized request URL (%s: %s)."
 
+// SessionHeader names the request header that keeps the state of parallel
+// clients apart.
+const SessionHeader = "Finance-Mock-Session"
+
 var pathParameterPattern = regexp.MustCompile(`\{(\w+)\}`)
 
 // Version is the mock server version number.
@@ -26,15 +35,92 @@ var Verbose = false
 
 // Handler takes care of requests based on resource types.
 type Handler interface {
-	Handle(r *http.Request, rte *regexp.Regexp) (statusCode int, responseData interface{})
+	Handle(r *http.Request, match *RouteMatch) (statusCode int, responseData interface{})
 }
 
 // StubServer handles incoming HTTP requests and responds to them appropriately
 // based off the set of routes that it's been configured with.
 type StubServer struct {
-	Spec       *fixture.Spec
-	Fixtures   *fixture.Fixtures
-	handlerMap map[*regexp.Regexp]*Handler
+	Spec        *fixture.Spec
+	Fixtures    *fixture.Fixtures
+	Scenarios   []*fixture.Scenario
+	Stubs       []*fixture.Stub
+	SessionIdle time.Duration
+	Static      bool
+	Strict      bool
+	RebaseTime  bool
+	CaptureDate string
+	Record      string
+	RecordFile  string
+	Drift       string
+	HAR         *fixture.HAR
+	HARIgnore   []string
+	routing     atomic.Value
+	reloadMu    sync.Mutex
+	overrides   []override
+	reload      *reloader
+	store       *fixtureStore
+	sessions    *sessionManager
+	journal     *journal
+	recorder    *recorder
+	har         *harReplay
+	drift       *driftDetector
+	rebase      *timeRebaser
+}
+
+// routing is a spec with the routes and settings built from it, swapped as
+// a whole on reload.
+type routing struct {
+	spec   *fixture.Spec
+	router *router
+	*settings
+}
+
+// settings are the latency, faults, rate limits, scenarios, chaos,
+// throttling and stubs requests are served with.
+type settings struct {
+	latency   *latencyInjector
+	faults    *faultInjector
+	limiter   *rateLimiter
+	scenarios *scenarioEngine
+	chaos     *chaosMonkey
+	throttle  *bandwidthThrottle
+	stubs     *stubRegistry
+}
+
+func newSettings() *settings {
+	return &settings{
+		latency:   newLatencyInjector(),
+		faults:    newFaultInjector(),
+		limiter:   newRateLimiter(),
+		scenarios: newScenarioEngine(),
+		chaos:     newChaosMonkey(),
+		throttle:  newBandwidthThrottle(),
+		stubs:     newStubRegistry(),
+	}
+}
+
+// override is a runtime change to the settings made through /config. It is
+// applied again over the settings of a reloaded spec.
+type override func(rtg *routing) error
+
+// pathRoute is a routed path and the routes of its methods.
+type pathRoute struct {
+	path    fixture.Path
+	pattern *pathPattern
+	allow   []string
+	methods map[string]*route
+}
+
+// route is a compiled spec path and the handler serving it.
+type route struct {
+	service   fixture.ServiceID
+	path      fixture.Path
+	pattern   *pathPattern
+	method    string
+	operation *fixture.Operation
+	params    []*param
+	handler   *Handler
 }
 
 // HandleRequest handles an HTTP request directed at the API stub.
@@ -50,29 +136,213 @@ func (s *StubServer) HandleRequest(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Move a session path prefix into the session header.
+	req, err := splitSessionPrefix(req)
+	if err != nil {
+		utils.Log(Verbose, "Couldn't parse session url: %v", err)
+		s.writeResponse(w, req, start, http.StatusNotFound, nil)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/config/") {
+		s.HandleConfigRequest(w, req)
+		return
+	}
+
+	// Journal the request once it's answered.
+	var rt *route
+	var stub *fixture.Stub
+	rec := &statusRecorder{ResponseWriter: w}
+	w = rec
+	defer func() { s.recordRequest(req, rt, stub, rec.status, start) }()
+
+	// Serve with the routes and settings of one spec even across a reload.
+	rtg := s.routes()
+
+	// Serve registered stubs.
+	if stub = rtg.stubs.match(req); stub != nil {
+		utils.Log(Verbose, "Matched stub: %v", stub.Name)
+		if s.writeStep(w, req, start, stub.Response) {
+			return
+		}
+	}
+
+	// Replay captured traffic.
+	if s.har != nil {
+		if entry := s.har.next(req); entry != nil {
+			s.writeHAREntry(w, req, start, entry)
+			return
+		}
+	}
+
 	// pattern-match a handler for the request.
-	hndlr, rte := s.routeRequest(req)
-	if hndlr == nil {
+	rt, pr, match := rtg.routeRequest(req)
+	if pr == nil {
 		utils.Log(Verbose, "Couldn't find handler for url: %v", req.URL.String())
-		s.writeResponse(w, req, start, http.StatusNotFound, nil)
+		s.writeResponse(w, req, start, http.StatusNotFound, rtg.router.notFound(req))
 		return
 	}
+	if rt == nil {
+		w.Header().Set("Allow", strings.Join(pr.allow, ", "))
+		if req.Method == http.MethodOptions {
+			s.writeRawResponse(w, req, start, http.StatusNoContent, "text/plain;charset=utf-8", nil)
+			return
+		}
+		utils.Log(Verbose, "Method not allowed: %v %v", req.Method, req.URL.String())
+		s.writeResponse(w, req, start, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	market := Market
+	sess := s.session(req)
+	if sess != nil {
+		market = sess.marketState()
+	}
+
+	// Serve from the current fixtures snapshot and market state.
+	ctx := withFixtures(req.Context(), s.fixtureStore(sess).current())
+	req = req.WithContext(withMarket(ctx, market))
 
-	// Build the response data.
-	h := *hndlr
-	statusCode, responseData := h.Handle(req, rte)
+	// Slow down and break the transport if asked to.
+	w = rtg.throttle.wrap(w, req, rt.path)
+	w = rtg.chaos.wrap(w, req, rt.path)
+
+	// Throttle aggressive clients.
+	if ok, wait := rtg.limiter.take(rt.path, req); !ok {
+		s.writeTooManyRequests(w, req, start, wait)
+		return
+	}
+
+	// Simulate network latency.
+	if !rtg.latency.wait(req.Context(), rt.path) {
+		utils.Log(Verbose, "Client went away during delay: %v", req.Context().Err())
+		return
+	}
+
+	// Play scripted responses.
+	symbols := requestSymbols(req, rt)
+	if step := rtg.scenarios.next(rt.path, symbols, sessionID(req)); step != nil {
+		if s.writeStep(w, req, start, step) {
+			return
+		}
+	}
+
+	// Simulate upstream failures.
+	if fault := rtg.faults.next(rt.path, symbols); fault != nil {
+		s.writeFault(w, req, start, fault)
+		return
+	}
+
+	// Reject the requests the spec doesn't allow and type the rest.
+	params, perr := parseParams(rt.params, req.URL.Query(), s.Strict)
+	if perr != nil {
+		utils.Log(Verbose, "Rejecting parameters: %v", perr)
+		status, body := perr.response()
+		s.writeResponse(w, req, start, status, body)
+		return
+	}
+	req = req.WithContext(withParams(req.Context(), params))
+
+	// Record what the fixtures lack from the upstream.
+	if s.recorder != nil && s.missingFixtures(req, rt, symbols) {
+		s.record(w, req, start, rt, symbols)
+		return
+	}
+
+	// Build the response data, from the example of operations without a
+	// resource.
+	var statusCode int
+	var responseData interface{}
+	if example := rt.operation.Example; rt.operation.ResourceID == "" && example != nil {
+		statusCode, responseData = exampleResponse(example)
+	} else {
+		h := *rt.handler
+		statusCode, responseData = h.Handle(req, match)
+	}
+
+	// Move fixture times onto today.
+	if s.rebase != nil {
+		responseData = s.rebaseTimes(req, rt, responseData)
+	}
+
+	// Evaluate fixture templates.
+	if !s.Static {
+		responseData = s.renderTemplates(req, rt, responseData)
+	}
+
+	// Compare the fixtures with the upstream.
+	if s.drift != nil && statusCode == http.StatusOK {
+		s.drift.check(req, rt, responseData)
+	}
 
 	s.writeResponse(w, req, start, statusCode, responseData)
 }
 
 // InitRouter maps server routes to handlers.
 func (s *StubServer) InitRouter() error {
+	s.store = newFixtureStore(s.Fixtures, s.validateResource)
+	s.journal = newJournal()
+	s.reload = &reloader{}
+	s.sessions = newSessionManager(s.SessionIdle, func() *fixtureStore {
+		return newFixtureStore(s.store.current(), s.validateResource)
+	})
+
+	s.reloadMu.Lock()
+	err := s.loadSpec(s.Spec, s.Fixtures)
+	s.reloadMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if s.Record != "" {
+		rec, err := newRecorder(s.Record, s.RecordFile)
+		if err != nil {
+			return fmt.Errorf("invalid record upstream: %v", err)
+		}
+		s.recorder = rec
+	}
+
+	if s.RebaseTime {
+		rebase, err := newTimeRebaser(s.CaptureDate, s.Fixtures)
+		if err != nil {
+			return err
+		}
+		s.rebase = rebase
+	}
+
+	if s.Drift != "" {
+		drift, err := newDriftDetector(s.Drift)
+		if err != nil {
+			return fmt.Errorf("invalid drift upstream: %v", err)
+		}
+		s.drift = drift
+	}
+
+	if s.HAR != nil {
+		ignore := s.HARIgnore
+		if ignore == nil {
+			ignore = DefaultHARIgnore
+		}
+		har, err := newHARReplay(s.HAR, ignore)
+		if err != nil {
+			return fmt.Errorf("invalid har: %v", err)
+		}
+		s.har = har
+	}
+
+	return nil
+}
+
+// loadSpec routes the paths of a spec, applies the settings of their
+// operations and adds the scenarios and stubs of the spec and the server.
+// Runtime overrides are applied over them, and requests see the new routes
+// and settings at once. It must be called with reloadMu held.
+func (s *StubServer) loadSpec(spec *fixture.Spec, fixtures *fixture.Fixtures) error {
 	var numServices int
 	var numRoutes int
 
-	s.handlerMap = make(map[*regexp.Regexp]*Handler)
-
-	for id, service := range s.Spec.Services {
+	rtg := &routing{spec: spec, settings: newSettings()}
+	var routes []*pathRoute
+	for id, service := range spec.Services {
 
 		var h Handler
 		switch id {
@@ -80,59 +350,292 @@ func (s *StubServer) InitRouter() error {
 			{
 				h = &YFinService{
 					Service:   service,
-					Resources: s.Fixtures.Resources[id],
+					Resources: fixtures.Resources[id],
 				}
 			}
 		default:
-			continue
+			g, err := newGenericService(id, service, fixtures.Resources[id])
+			if err != nil {
+				return err
+			}
+			h = g
 		}
 
 		numServices++
 
-		for path := range service.Paths {
-			numRoutes++
+		for path, item := range service.Paths {
+			utils.Log(Verbose, "Routing path: %v", path)
+
+			// Settings apply to every method of a path.
+			settings, err := pathSettings(path, item)
+			if err != nil {
+				return err
+			}
+
+			pattern := newPathPattern(path, settings.Prefix != nil && *settings.Prefix)
+			pr := &pathRoute{path: path, pattern: pattern, allow: item.Allow(), methods: make(map[string]*route)}
+			routes = append(routes, pr)
 
-			route := compilePath(path)
-			utils.Log(Verbose, "Compiled route: %v", route.String())
+			for _, method := range sortedMethods(item) {
+				op := item[method]
+				numRoutes++
 
-			// Set the routes and operations.
-			s.handlerMap[route] = &h
+				params, err := compileParams(op.Parameters)
+				if err != nil {
+					return fmt.Errorf("invalid parameters for %v %v: %v", method, path, err)
+				}
+
+				// Set the routes and operations.
+				pr.methods[method] = &route{service: id, path: path, pattern: pattern, method: method, operation: op, params: params, handler: &h}
+			}
+
+			err = rtg.applySettings(path, settings)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
 	utils.Log(Verbose, "Routing to %v service(s) and %v route(s)",
 		numServices, numRoutes)
 
+	rtg.router = newRouter(routes)
+
+	for _, scenario := range append(append([]*fixture.Scenario{}, spec.Scenarios...), s.Scenarios...) {
+		err := rtg.addScenario(scenario)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, stub := range append(append([]*fixture.Stub{}, spec.Stubs...), s.Stubs...) {
+		_, err := rtg.stubs.add(stub)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keep the runtime overrides that still apply.
+	var overrides []override
+	for _, o := range s.overrides {
+		err := o(rtg)
+		if err != nil {
+			utils.Log(true, "Dropping runtime config after reload: %v", err)
+			continue
+		}
+		overrides = append(overrides, o)
+	}
+	s.overrides = overrides
+
+	s.routing.Store(rtg)
+	return nil
+}
+
+// configure applies a runtime change to the current settings and keeps it
+// for reloads.
+func (s *StubServer) configure(o override) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	err := o(s.routes())
+	if err != nil {
+		return err
+	}
+	s.overrides = append(s.overrides, o)
 	return nil
 }
 
-func (s *StubServer) routeRequest(r *http.Request) (*Handler, *regexp.Regexp) {
-	for rte, hdlr := range s.handlerMap {
-		if rte.MatchString(r.URL.Path) {
-			return hdlr, rte
+// pathSettings gathers the settings the methods of a path declare. Methods
+// declaring a setting differently conflict, as it applies to all of them.
+func pathSettings(path fixture.Path, item fixture.PathItem) (*fixture.Operation, error) {
+	settings := &fixture.Operation{}
+	declared := make(map[string]string)
+	declare := func(name, method string, v, current interface{}) (bool, error) {
+		if other, ok := declared[name]; ok {
+			if !reflect.DeepEqual(v, current) {
+				return false, fmt.Errorf("conflicting %v for %v: %v and %v differ, and settings apply to every method of a path", name, path, other, method)
+			}
+			return false, nil
 		}
+		declared[name] = method
+		return true, nil
 	}
-	return nil, nil
+
+	for _, method := range sortedMethods(item) {
+		op := item[method]
+		var set bool
+		var err error
+		if op.Latency != nil {
+			if set, err = declare("latency", method, op.Latency, settings.Latency); set {
+				settings.Latency = op.Latency
+			}
+		}
+		if err == nil && op.Faults != nil {
+			if set, err = declare("faults", method, op.Faults, settings.Faults); set {
+				settings.Faults = op.Faults
+			}
+		}
+		if err == nil && op.RateLimit != nil {
+			if set, err = declare("rate limit", method, op.RateLimit, settings.RateLimit); set {
+				settings.RateLimit = op.RateLimit
+			}
+		}
+		if err == nil && op.Chaos != nil {
+			if set, err = declare("chaos", method, op.Chaos, settings.Chaos); set {
+				settings.Chaos = op.Chaos
+			}
+		}
+		if err == nil && op.Throttle != nil {
+			if set, err = declare("throttle", method, op.Throttle, settings.Throttle); set {
+				settings.Throttle = op.Throttle
+			}
+		}
+		if err == nil && op.Prefix != nil {
+			if set, err = declare("prefix", method, op.Prefix, settings.Prefix); set {
+				settings.Prefix = op.Prefix
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
 }
 
-func compilePath(path fixture.Path) *regexp.Regexp {
-	pattern := `\A`
-	parts := strings.Split(string(path), "/")
+// applySettings applies the latency, faults, rate limit, chaos and throttle
+// settings of an operation to its path.
+func (rtg *routing) applySettings(path fixture.Path, op *fixture.Operation) error {
+	if op.Latency != nil {
+		err := rtg.latency.set(path, op.Latency)
+		if err != nil {
+			return fmt.Errorf("invalid latency for %v: %v", path, err)
+		}
+	}
 
-	for _, part := range parts {
-		if part == "" {
-			continue
+	for _, fault := range op.Faults {
+		err := rtg.faults.set(path, fault)
+		if err != nil {
+			return fmt.Errorf("invalid fault for %v: %v", path, err)
 		}
+	}
 
-		submatches := pathParameterPattern.FindAllStringSubmatch(part, -1)
-		if submatches == nil {
-			pattern += `/` + part
-		} else {
-			pattern += `/(?P<` + submatches[0][1] + `>[\w-_.]+)`
+	if op.RateLimit != nil {
+		err := rtg.limiter.set(path, op.RateLimit)
+		if err != nil {
+			return fmt.Errorf("invalid rate limit for %v: %v", path, err)
 		}
 	}
 
-	return regexp.MustCompile(pattern)
+	if op.Chaos != nil {
+		err := rtg.chaos.set(path, op.Chaos)
+		if err != nil {
+			return fmt.Errorf("invalid chaos for %v: %v", path, err)
+		}
+	}
+
+	if op.Throttle != nil {
+		err := rtg.throttle.set(path, op.Throttle)
+		if err != nil {
+			return fmt.Errorf("invalid throttle for %v: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// sortedMethods lists the methods of a path in order.
+func sortedMethods(item fixture.PathItem) []string {
+	methods := make([]string, 0, len(item))
+	for method := range item {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// routes gets the current spec and routes.
+func (s *StubServer) routes() *routing {
+	return s.routing.Load().(*routing)
+}
+
+// routeRequest finds the route of a request and how it matched. When its
+// path is routed but not its method, it returns no route and the routed path
+// with the methods it allows. HEAD requests take the GET route unless
+// declared.
+func (rtg *routing) routeRequest(r *http.Request) (*route, *pathRoute, *RouteMatch) {
+	pr, params, rest := rtg.router.match(r.URL.EscapedPath())
+	if pr == nil {
+		return nil, nil, nil
+	}
+	rt := pr.methods[r.Method]
+	if rt == nil && r.Method == http.MethodHead {
+		rt = pr.methods[http.MethodGet]
+	}
+	if rt == nil {
+		return nil, pr, nil
+	}
+	return rt, pr, &RouteMatch{Path: rt.path, Method: rt.method, Operation: rt.operation, Params: params, Rest: rest}
+}
+
+// match matches the path of a request against the route.
+func (rt *route) match(r *http.Request) *RouteMatch {
+	params, rest, ok := rt.pattern.match(r.URL.EscapedPath())
+	if !ok {
+		return nil
+	}
+	return &RouteMatch{Path: rt.path, Method: rt.method, Operation: rt.operation, Params: params, Rest: rest}
+}
+
+// addScenario adds a scenario for a routed path.
+func (rtg *routing) addScenario(scenario *fixture.Scenario) error {
+	if !rtg.hasPath(scenario.Path) {
+		return fmt.Errorf("scenario %v has an unknown path: %v", scenario.Name, scenario.Path)
+	}
+	return rtg.scenarios.add(scenario)
+}
+
+// sessionID identifies the client session of a request.
+func sessionID(r *http.Request) string {
+	return r.Header.Get(SessionHeader)
+}
+
+// hasPath reports whether a spec path is routed.
+func (s *StubServer) hasPath(path fixture.Path) bool {
+	return s.routes().hasPath(path)
+}
+
+func (rtg *routing) hasPath(path fixture.Path) bool {
+	for _, pr := range rtg.router.routes {
+		if pr.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// requestSymbols lists the symbols a request asks for, either in the symbols
+// query parameter or in the path.
+func requestSymbols(r *http.Request, rt *route) []string {
+	if symbols := r.URL.Query().Get("symbols"); symbols != "" {
+		return strings.Split(symbols, ",")
+	}
+
+	match := rt.match(r)
+	if match == nil {
+		return nil
+	}
+	if symbol := match.symbol(); symbol != "" {
+		return []string{symbol}
+	}
+	return nil
+}
+
+// exampleResponse is the response of an operation's example.
+func exampleResponse(example *fixture.Step) (int, interface{}) {
+	status := example.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return status, example.Body
 }
 
 func isCurl(userAgent string) bool {
@@ -163,17 +666,24 @@ func (s *StubServer) writeResponse(w http.ResponseWriter, r *http.Request, start
 		return
 	}
 
+	s.writeRawResponse(w, r, start, status, "application/json;charset=utf-8", encodedData)
+}
+
+// writeRawResponse writes an encoded response body.
+func (s *StubServer) writeRawResponse(w http.ResponseWriter, r *http.Request, start time.Time, status int, contentType string, data []byte) {
+
 	// Set headers.
 	w.Header().Set("Finance-Mock-Version", Version)
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 
 	// Write response.
-	_, err = w.Write(encodedData)
+	_, err := w.Write(data)
 	if err != nil {
 		utils.Log(Verbose, "Error writing to client: %v", err)
 	}
 
-	utils.Log(Verbose, "Response data: %s", encodedData)
+	utils.Log(Verbose, "Response data: %s", data)
 
 	// Log result.
 	utils.Log(Verbose, "Response: elapsed=%v status=%v", time.Now().Sub(start), status)
@@ -1,19 +1,19 @@
 package server
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/piquette/finance-mock/fixture"
 	assert "github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
 )
 
-func TestCompilePath(t *testing.T) {
-	assert.Equal(t, `\A/v7/quote`,
-		compilePath(fixture.Path("/v7/quote")).String())
-	assert.Equal(t, `\A/v7/quote/(?P<symbols>[\w-_.]+)`,
-		compilePath(fixture.Path("/v7/quote/{symbols}")).String())
-}
-
 func TestIsCurl(t *testing.T) {
 	testCases := []struct {
 		userAgent string
@@ -34,3 +34,68 @@ func TestIsCurl(t *testing.T) {
 		})
 	}
 }
+
+// newTestServer builds a stub server from the repo's spec and fixtures.
+func newTestServer(t *testing.T) *StubServer {
+	data, err := ioutil.ReadFile("../fixture/spec.yml")
+	assert.NoError(t, err)
+	var spec fixture.Spec
+	assert.NoError(t, yaml.Unmarshal(data, &spec))
+
+	data, err = ioutil.ReadFile("../fixture/resources.json")
+	assert.NoError(t, err)
+	var fixtures fixture.Fixtures
+	assert.NoError(t, json.Unmarshal(data, &fixtures))
+
+	s := &StubServer{Spec: &spec, Fixtures: &fixtures}
+	assert.NoError(t, s.InitRouter())
+	return s
+}
+
+func doRequest(t *testing.T, s *StubServer, method, target string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, target, nil)
+	w := httptest.NewRecorder()
+	s.HandleRequest(w, req)
+
+	var body map[string]interface{}
+	if w.Body.Len() > 0 {
+		json.Unmarshal(w.Body.Bytes(), &body)
+	}
+	return w.Code, body
+}
+
+func doConfigRequest(t *testing.T, s *StubServer, method, target string, form url.Values) (int, interface{}) {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.HandleConfigRequest(w, req)
+
+	var body interface{}
+	if w.Body.Len() > 0 {
+		json.Unmarshal(w.Body.Bytes(), &body)
+	}
+	return w.Code, body
+}
+
+func TestServeOperationExample(t *testing.T) {
+	spec, err := fixture.ParseOpenAPI([]byte(`
+openapi: 3.0.0
+paths:
+  /v1/finance/trending/{region}:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              example: {finance: {result: [{count: 1}]}}
+`))
+	assert.NoError(t, err)
+
+	s := newTestServer(t)
+	s.Spec = spec
+	assert.NoError(t, s.InitRouter())
+
+	status, body := doRequest(t, s, "GET", "/v1/finance/trending/US")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"result": []interface{}{map[string]interface{}{"count": 1.0}}}, body["finance"])
+}
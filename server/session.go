@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+)
+
+const (
+	// sessionPrefix starts request paths scoped to a session, e.g.
+	// /session/{id}/v7/finance/quote.
+	sessionPrefix = "/session/"
+
+	// DefaultSessionIdle is how long sessions are kept without requests.
+	DefaultSessionIdle = 10 * time.Minute
+)
+
+// session is the isolated state of one client: fixture overrides and market
+// state. The requests it made are journaled with its id.
+type session struct {
+	mu       sync.Mutex
+	id       string
+	store    *fixtureStore
+	market   MarketState
+	lastSeen time.Time
+}
+
+// sessionInfo reports a session.
+type sessionInfo struct {
+	ID       string          `json:"id"`
+	Market   MarketState     `json:"market"`
+	LastSeen time.Time       `json:"lastSeen"`
+	Requests []*journalEntry `json:"requests,omitempty"`
+}
+
+// marketState gets the market state of the session.
+func (s *session) marketState() MarketState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.market
+}
+
+// setMarketState sets the market state of the session.
+func (s *session) setMarketState(state MarketState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.market = state
+}
+
+func (s *session) info() *sessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &sessionInfo{
+		ID:       s.id,
+		Market:   s.market,
+		LastSeen: s.lastSeen,
+	}
+}
+
+// sessionManager creates sessions lazily and expires idle ones.
+type sessionManager struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	idle     time.Duration
+	sessions map[string]*session
+	newStore func() *fixtureStore
+}
+
+func newSessionManager(idle time.Duration, newStore func() *fixtureStore) *sessionManager {
+	if idle <= 0 {
+		idle = DefaultSessionIdle
+	}
+	return &sessionManager{
+		now:      time.Now,
+		idle:     idle,
+		sessions: make(map[string]*session),
+		newStore: newStore,
+	}
+}
+
+// get gets a session, creating it on first use. Sessions start with the
+// current fixtures and market state.
+func (m *sessionManager) get(id string) *session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.expire(now)
+
+	sess := m.sessions[id]
+	if sess == nil {
+		sess = &session{id: id, store: m.newStore(), market: Market}
+		m.sessions[id] = sess
+	}
+
+	sess.mu.Lock()
+	sess.lastSeen = now
+	sess.mu.Unlock()
+	return sess
+}
+
+// find gets a session without creating or touching it.
+func (m *sessionManager) find(id string) *session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(m.now())
+	return m.sessions[id]
+}
+
+// remove drops a session.
+func (m *sessionManager) remove(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.sessions[id]
+	delete(m.sessions, id)
+	return ok
+}
+
+// reload restarts the fixtures of every session from loaded fixtures,
+// dropping their overrides. Market states are kept.
+func (m *sessionManager) reload(loaded *fixture.Fixtures) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, sess := range m.sessions {
+		sess.store.load(loaded)
+	}
+}
+
+// list reports every live session.
+func (m *sessionManager) list() []*sessionInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire(m.now())
+
+	list := []*sessionInfo{}
+	for _, sess := range m.sessions {
+		list = append(list, sess.info())
+	}
+	return list
+}
+
+// expire drops the sessions idle for too long.
+func (m *sessionManager) expire(now time.Time) {
+	for id, sess := range m.sessions {
+		sess.mu.Lock()
+		idle := now.Sub(sess.lastSeen)
+		sess.mu.Unlock()
+		if idle > m.idle {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+// splitSessionPrefix moves a /session/{id} path prefix into the session
+// header, so sessions are identified the same way from then on. On error the
+// request is returned as is.
+func splitSessionPrefix(r *http.Request) (*http.Request, error) {
+	if !strings.HasPrefix(r.URL.Path, sessionPrefix) {
+		return r, nil
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, sessionPrefix)
+	i := strings.Index(rest, "/")
+	if i <= 0 {
+		return r, fmt.Errorf("session path needs an id and a path")
+	}
+
+	r2 := r.WithContext(r.Context())
+	u := *r.URL
+	u.Path = rest[i:]
+	u.RawPath = ""
+	r2.URL = &u
+	r2.Header = make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		r2.Header[k] = v
+	}
+	r2.Header.Set(SessionHeader, rest[:i])
+	return r2, nil
+}
+
+// session gets the session of a request, or nil for requests without one.
+func (s *StubServer) session(r *http.Request) *session {
+	id := sessionID(r)
+	if id == "" {
+		return nil
+	}
+	return s.sessions.get(id)
+}
+
+// fixtureStore gets the fixtures of a request's session, or the shared ones.
+func (s *StubServer) fixtureStore(sess *session) *fixtureStore {
+	if sess != nil {
+		return sess.store
+	}
+	return s.store
+}
+
+// marketKey is the context key of the market state serving a request.
+type marketKey struct{}
+
+// withMarket sets the market state serving a request.
+func withMarket(ctx context.Context, state MarketState) context.Context {
+	return context.WithValue(ctx, marketKey{}, state)
+}
+
+// marketFrom gets the market state serving a request.
+func marketFrom(ctx context.Context) MarketState {
+	state, _ := ctx.Value(marketKey{}).(MarketState)
+	return state
+}
+
+// statusRecorder remembers the status written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status.
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write records an implicit 200 status.
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(data)
+}
+
+// Flush passes flushes through.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes hijacks through.
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection can't be hijacked")
+	}
+	return hj.Hijack()
+}
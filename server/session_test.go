@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestSessionIsolation(t *testing.T) {
+	s := newTestServer(t)
+
+	do := func(method, target, session, body string) (*httptest.ResponseRecorder, *http.Request) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if session != "" {
+			req.Header.Set(SessionHeader, session)
+		}
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		s.HandleRequest(w, req)
+		return w, req
+	}
+
+	// Market state and fixture overrides only apply to the session.
+	w, _ := do("POST", "/config/", "a", url.Values{"state": {"pre"}}.Encode())
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = do("PATCH", "/session/a/config/fixtures/yfin/quote/AAPL/PRE", "", `{"regularMarketPrice": 1.5}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w, _ = do("GET", "/session/a/v7/finance/quote?symbols=AAPL", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"marketState":"PRE"`)
+	assert.Contains(t, w.Body.String(), `"regularMarketPrice":1.5`)
+
+	w, _ = do("GET", "/v7/finance/quote?symbols=AAPL", "b", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"marketState":"POST"`)
+	assert.NotContains(t, w.Body.String(), `"regularMarketPrice":1.5`)
+	assert.Equal(t, MarketStatePost, Market)
+
+	// Sessions list their own requests.
+	status, body := doConfigRequest(t, s, "GET", "/config/sessions/a", nil)
+	assert.Equal(t, http.StatusOK, status)
+	info := body.(map[string]interface{})
+	assert.Equal(t, "pre", info["market"])
+	requests := info["requests"].([]interface{})
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "/v7/finance/quote", requests[0].(map[string]interface{})["path"])
+
+	status, body = doConfigRequest(t, s, "GET", "/config/sessions", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body, 2)
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/sessions/a", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = doConfigRequest(t, s, "GET", "/config/sessions/a", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	w, _ = do("GET", "/session/", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Unix(1531612800, 0)
+	s.sessions.now = func() time.Time { return now }
+
+	s.sessions.get("a")
+	now = now.Add(DefaultSessionIdle / 2)
+	s.sessions.get("b")
+	now = now.Add(DefaultSessionIdle/2 + time.Second)
+
+	assert.Nil(t, s.sessions.find("a"))
+	assert.NotNil(t, s.sessions.find("b"))
+}
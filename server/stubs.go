@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/piquette/finance-mock/fixture"
+)
+
+// stubRegistry holds canned responses consulted before routing. The stubs
+// slice is replaced rather than changed in place, so matching can go through
+// a copy of it without the lock.
+type stubRegistry struct {
+	mu     sync.Mutex
+	stubs  []*compiledStub
+	nextID int
+}
+
+// compiledStub is a stub with its regular expressions compiled.
+type compiledStub struct {
+	stub    *fixture.Stub
+	path    *regexp.Regexp
+	query   map[string]*valueMatcher
+	headers map[string]*valueMatcher
+	body    *valueMatcher
+}
+
+// valueMatcher is a value matcher with its regular expression compiled.
+type valueMatcher struct {
+	*fixture.ValueMatcher
+	matches *regexp.Regexp
+}
+
+func newStubRegistry() *stubRegistry {
+	return &stubRegistry{}
+}
+
+// add adds a stub, replacing one with the same name, and returns its name.
+// Unnamed stubs get one. The registry keeps a copy, as the stubs of the spec
+// are added again on reload while requests may be serving them.
+func (reg *stubRegistry) add(stub *fixture.Stub) (string, error) {
+	if stub.Response == nil {
+		return "", fmt.Errorf("stub %v has no response", stub.Name)
+	}
+	if stub.Response.Status != 0 && http.StatusText(stub.Response.Status) == "" {
+		return "", fmt.Errorf("stub %v has an unknown status: %v", stub.Name, stub.Response.Status)
+	}
+
+	copied := *stub
+	stub = &copied
+	if stub.Request == nil {
+		stub.Request = &fixture.StubRequest{}
+	}
+	stub.Response = normalizeStep(stub.Response)
+
+	c, err := compileStub(stub)
+	if err != nil {
+		return "", fmt.Errorf("stub %v: %v", stub.Name, err)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if stub.Name == "" {
+		reg.nextID++
+		stub.Name = fmt.Sprintf("stub-%d", reg.nextID)
+	}
+
+	stubs := make([]*compiledStub, 0, len(reg.stubs)+1)
+	for _, s := range reg.stubs {
+		if s.stub.Name != stub.Name {
+			stubs = append(stubs, s)
+		}
+	}
+	stubs = append(stubs, c)
+	sort.SliceStable(stubs, func(a, b int) bool {
+		return stubs[a].stub.Priority < stubs[b].stub.Priority
+	})
+	reg.stubs = stubs
+	return stub.Name, nil
+}
+
+// remove removes a stub, or all of them if the name is empty.
+func (reg *stubRegistry) remove(name string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if name == "" {
+		reg.stubs = nil
+		return
+	}
+	stubs := make([]*compiledStub, 0, len(reg.stubs))
+	for _, s := range reg.stubs {
+		if s.stub.Name != name {
+			stubs = append(stubs, s)
+		}
+	}
+	reg.stubs = stubs
+}
+
+// list lists the stubs in the order they are consulted.
+func (reg *stubRegistry) list() []*fixture.Stub {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	list := []*fixture.Stub{}
+	for _, s := range reg.stubs {
+		list = append(list, s.stub)
+	}
+	return list
+}
+
+// match finds the first stub matching a request.
+func (reg *stubRegistry) match(r *http.Request) *fixture.Stub {
+	reg.mu.Lock()
+	stubs := reg.stubs
+	reg.mu.Unlock()
+
+	if len(stubs) == 0 {
+		return nil
+	}
+
+	// Read the body once if needed and put it back for the handlers.
+	var body []byte
+	if r.Body != nil && needsBody(stubs) {
+		body, _ = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	for _, s := range stubs {
+		if s.matches(r, body) {
+			return s.stub
+		}
+	}
+	return nil
+}
+
+func needsBody(stubs []*compiledStub) bool {
+	for _, s := range stubs {
+		if s.body != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func compileStub(stub *fixture.Stub) (*compiledStub, error) {
+	var err error
+	c := &compiledStub{
+		stub:    stub,
+		query:   make(map[string]*valueMatcher),
+		headers: make(map[string]*valueMatcher),
+	}
+
+	if stub.Request.Path != "" {
+		c.path, err = regexp.Compile(`\A(?:` + stub.Request.Path + `)\z`)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name, m := range stub.Request.Query {
+		if c.query[name], err = compileValueMatcher(m); err != nil {
+			return nil, err
+		}
+	}
+	for name, m := range stub.Request.Headers {
+		if c.headers[name], err = compileValueMatcher(m); err != nil {
+			return nil, err
+		}
+	}
+	if stub.Request.Body != nil {
+		if c.body, err = compileValueMatcher(stub.Request.Body); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func compileValueMatcher(m *fixture.ValueMatcher) (*valueMatcher, error) {
+	if m == nil {
+		m = &fixture.ValueMatcher{}
+	}
+	v := &valueMatcher{ValueMatcher: m}
+	if m.Matches != "" {
+		re, err := regexp.Compile(m.Matches)
+		if err != nil {
+			return nil, err
+		}
+		v.matches = re
+	}
+	return v, nil
+}
+
+func (c *compiledStub) matches(r *http.Request, body []byte) bool {
+	req := c.stub.Request
+	if req.Method != "" && !strings.EqualFold(req.Method, r.Method) {
+		return false
+	}
+	if c.path != nil && !c.path.MatchString(r.URL.Path) {
+		return false
+	}
+
+	query := r.URL.Query()
+	for name, m := range c.query {
+		values, ok := query[name]
+		if !m.match(values, ok) {
+			return false
+		}
+	}
+	for name, m := range c.headers {
+		values, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !m.match(values, ok) {
+			return false
+		}
+	}
+	if c.body != nil && !c.body.match([]string{string(body)}, len(body) > 0) {
+		return false
+	}
+	return true
+}
+
+// match checks the first value. Matchers without conditions check presence.
+func (v *valueMatcher) match(values []string, present bool) bool {
+	if v.Present != nil {
+		if *v.Present != present {
+			return false
+		}
+	} else if !present {
+		return false
+	}
+	if !present {
+		return true
+	}
+
+	value := values[0]
+	if v.Equals != nil && *v.Equals != value {
+		return false
+	}
+	if v.matches != nil && !v.matches.MatchString(value) {
+		return false
+	}
+	if v.Contains != "" && !strings.Contains(value, v.Contains) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func stringPtr(s string) *string { return &s }
+
+func TestStubMatching(t *testing.T) {
+	present := false
+	reg := newStubRegistry()
+	_, err := reg.add(&fixture.Stub{
+		Name: "query",
+		Request: &fixture.StubRequest{
+			Method: "GET",
+			Path:   "/v7/finance/quote",
+			Query: map[string]*fixture.ValueMatcher{
+				"symbols": {Matches: "^AAPL"},
+				"crumb":   {Present: &present},
+			},
+		},
+		Response: &fixture.Step{Status: http.StatusTeapot},
+	})
+	assert.NoError(t, err)
+	_, err = reg.add(&fixture.Stub{
+		Name: "body",
+		Request: &fixture.StubRequest{
+			Method:  "POST",
+			Path:    "/v1/.*",
+			Headers: map[string]*fixture.ValueMatcher{"x-test": {Equals: stringPtr("yes")}},
+			Body:    &fixture.ValueMatcher{Contains: "needle"},
+		},
+		Response: &fixture.Step{Status: http.StatusCreated},
+	})
+	assert.NoError(t, err)
+
+	match := func(r *http.Request) string {
+		if stub := reg.match(r); stub != nil {
+			return stub.Name
+		}
+		return ""
+	}
+
+	assert.Equal(t, "query", match(httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL,SPY", nil)))
+	assert.Equal(t, "", match(httptest.NewRequest("POST", "/v7/finance/quote?symbols=AAPL", nil)))
+	assert.Equal(t, "", match(httptest.NewRequest("GET", "/v7/finance/quote?symbols=SPY", nil)))
+	assert.Equal(t, "", match(httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL&crumb=x", nil)))
+	// Paths match whole.
+	assert.Equal(t, "", match(httptest.NewRequest("GET", "/v7/finance/quote/extra?symbols=AAPL", nil)))
+
+	req := httptest.NewRequest("POST", "/v1/anything", strings.NewReader("hay needle hay"))
+	req.Header.Set("X-Test", "yes")
+	assert.Equal(t, "body", match(req))
+
+	// The body is still there for the handlers.
+	var buf bytes.Buffer
+	buf.ReadFrom(req.Body)
+	assert.Equal(t, "hay needle hay", buf.String())
+
+	req = httptest.NewRequest("POST", "/v1/anything", strings.NewReader("hay"))
+	req.Header.Set("X-Test", "yes")
+	assert.Equal(t, "", match(req))
+}
+
+func TestStubPriority(t *testing.T) {
+	reg := newStubRegistry()
+	for _, stub := range []*fixture.Stub{
+		{Name: "late", Priority: 5, Response: &fixture.Step{}},
+		{Name: "early", Priority: 1, Response: &fixture.Step{}},
+		{Response: &fixture.Step{}},
+	} {
+		_, err := reg.add(stub)
+		assert.NoError(t, err)
+	}
+
+	var names []string
+	for _, stub := range reg.list() {
+		names = append(names, stub.Name)
+	}
+	assert.Equal(t, []string{"stub-1", "early", "late"}, names)
+
+	// Replacing keeps one stub per name.
+	_, err := reg.add(&fixture.Stub{Name: "early", Priority: 9, Response: &fixture.Step{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "early", reg.list()[2].Name)
+	assert.Len(t, reg.list(), 3)
+
+	reg.remove("late")
+	assert.Len(t, reg.list(), 2)
+	reg.remove("")
+	assert.Empty(t, reg.list())
+
+	_, err = reg.add(&fixture.Stub{Name: "none"})
+	assert.Error(t, err)
+	_, err = reg.add(&fixture.Stub{Name: "bad", Request: &fixture.StubRequest{Path: "("}, Response: &fixture.Step{}})
+	assert.Error(t, err)
+}
+
+func TestStubServing(t *testing.T) {
+	s := newTestServer(t)
+
+	stub := []byte(`{
+		"name": "apple",
+		"request": {"path": "/v7/finance/quote", "query": {"symbols": {"equals": "AAPL"}}},
+		"response": {"status": 200, "headers": {"X-Stub": "apple"}, "body": {"quoteResponse": {"result": [{"symbol": "AAPL", "regularMarketPrice": 1.5}], "error": null}}}
+	}`)
+	req := httptest.NewRequest("POST", "/config/stubs", bytes.NewReader(stub))
+	w := httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL", nil)
+	w = httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "apple", w.Header().Get("X-Stub"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 1.5, quotePrice(t, body))
+
+	// Unmatched requests fall through to the fixtures.
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=SPY")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEqual(t, 1.5, quotePrice(t, body))
+
+	// Stubs can match paths without a route.
+	name, err := s.routes().stubs.add(&fixture.Stub{
+		Request:  &fixture.StubRequest{Path: "/v1/ping"},
+		Response: &fixture.Step{Status: http.StatusOK, Body: "pong"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "stub-1", name)
+	status, _ = doRequest(t, s, "GET", "/v1/ping")
+	assert.Equal(t, http.StatusOK, status)
+
+	entries := s.journal.find(&requestMatcher{Stub: "apple"})
+	assert.Len(t, entries, 1)
+
+	status, list := doConfigRequest(t, s, "GET", "/config/stubs", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, list, 2)
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/stubs?name=apple", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, body = doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEqual(t, 1.5, quotePrice(t, body))
+
+	req = httptest.NewRequest("POST", "/config/stubs", strings.NewReader(`{"name": "broken"}`))
+	w = httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Run with -race: stubs change while requests are matched against them.
+func TestStubConcurrentChanges(t *testing.T) {
+	s := newTestServer(t)
+	_, err := s.routes().stubs.add(&fixture.Stub{
+		Name:     "apple",
+		Priority: 10,
+		Request:  &fixture.StubRequest{Path: "/v7/finance/quote"},
+		Response: &fixture.Step{Status: http.StatusOK, Headers: map[string]string{"X-Stub": "apple"}, Body: "apple"},
+	})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			name := fmt.Sprintf("other-%d", i%4)
+			if i%2 == 0 {
+				stub := fmt.Sprintf(`{"name": %q, "priority": %d, "request": {"path": "/v1/other"}, "response": {"status": 204}}`, name, i%20)
+				w := httptest.NewRecorder()
+				s.HandleRequest(w, httptest.NewRequest("POST", "/config/stubs", strings.NewReader(stub)))
+			} else {
+				w := httptest.NewRecorder()
+				s.HandleRequest(w, httptest.NewRequest("DELETE", "/config/stubs?name="+name, nil))
+			}
+		}
+	}()
+
+	served := make(chan string, 400)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				w := httptest.NewRecorder()
+				s.HandleRequest(w, httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL", nil))
+				served <- w.Header().Get("X-Stub")
+			}
+		}()
+	}
+
+	for i := 0; i < 400; i++ {
+		assert.Equal(t, "apple", <-served)
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestStubReload(t *testing.T) {
+	s := newTestServer(t)
+	var stub fixture.Stub
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+name: stale
+request: {path: /v7/finance/quote}
+response:
+  body: {quoteResponse: {result: [{symbol: AAPL, regularMarketPrice: 1.5}], error: null}}
+`), &stub))
+	s.Stubs = []*fixture.Stub{&stub}
+	assert.NoError(t, s.InitRouter())
+
+	req := httptest.NewRequest("POST", "/config/stubs", strings.NewReader(`{"request": {"path": "/v1/ping"}, "response": {"body": "pong"}}`))
+	w := httptest.NewRecorder()
+	s.HandleRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var added map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &added))
+
+	// Reloads add the stub again while requests serve it.
+	reloaded := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 5 && err == nil; i++ {
+			err = s.Reload(s.Spec, s.Fixtures)
+		}
+		reloaded <- err
+	}()
+	for i := 0; i < 50; i++ {
+		status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 1.5, quotePrice(t, body))
+	}
+	assert.NoError(t, <-reloaded)
+
+	// The stub given is left alone, and unnamed stubs keep their names.
+	assert.IsType(t, map[interface{}]interface{}{}, stub.Response.Body)
+	var names []string
+	for _, stub := range s.routes().stubs.list() {
+		names = append(names, stub.Name)
+	}
+	assert.Equal(t, []string{"stale", added["name"]}, names)
+}
@@ -0,0 +1,368 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/piquette/finance-mock/utils"
+)
+
+// templatePattern finds the template expressions in fixture strings.
+var templatePattern = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// durationUnits are the units of duration literals, in seconds.
+var durationUnits = map[string]float64{
+	"s": 1,
+	"m": 60,
+	"h": 60 * 60,
+	"d": 24 * 60 * 60,
+	"w": 7 * 24 * 60 * 60,
+}
+
+// templateEnv is what template expressions refer to. Times are epoch
+// seconds, durations like 15m are seconds too, and other names are fields of
+// the object holding the template. The symbol is that of the closest object
+// with a symbol field.
+type templateEnv struct {
+	now      time.Time
+	request  *http.Request
+	symbols  []string
+	symbol   string
+	object   map[string]interface{}
+	visiting map[string]bool
+}
+
+// renderTemplates evaluates the template expressions in a response. Strings
+// holding a single expression take the type of its value, others have the
+// values written in. Expressions that fail are left as they are.
+func (s *StubServer) renderTemplates(r *http.Request, rt *route, data interface{}) interface{} {
+	encoded, err := json.Marshal(data)
+	if err != nil || !bytes.Contains(encoded, []byte("{{")) {
+		return data
+	}
+
+	var tree interface{}
+	if err := json.Unmarshal(encoded, &tree); err != nil {
+		return data
+	}
+
+	env := &templateEnv{now: time.Now(), request: r, symbols: requestSymbols(r, rt)}
+	return env.render(tree, nil, "")
+}
+
+func (env *templateEnv) render(node interface{}, object map[string]interface{}, symbol string) interface{} {
+	switch t := node.(type) {
+	case map[string]interface{}:
+		if s, ok := t["symbol"].(string); ok && !strings.Contains(s, "{{") {
+			symbol = s
+		}
+		for k, v := range t {
+			t[k] = env.render(v, t, symbol)
+		}
+		return t
+	case []interface{}:
+		for i, v := range t {
+			t[i] = env.render(v, object, symbol)
+		}
+		return t
+	case string:
+		if !strings.Contains(t, "{{") {
+			return t
+		}
+		e := &templateEnv{now: env.now, request: env.request, symbols: env.symbols, symbol: symbol, object: object, visiting: map[string]bool{}}
+		v, err := e.renderString(t)
+		if err != nil {
+			utils.Log(Verbose, "Couldn't evaluate template %q: %v", t, err)
+			return t
+		}
+		return v
+	}
+	return node
+}
+
+func (env *templateEnv) renderString(s string) (interface{}, error) {
+	matches := templatePattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return env.eval(s[matches[0][2]:matches[0][3]])
+	}
+
+	var err error
+	out := templatePattern.ReplaceAllStringFunc(s, func(m string) string {
+		v, e := env.eval(m[2 : len(m)-2])
+		if e != nil {
+			err = e
+			return m
+		}
+		return formatTemplateValue(v)
+	})
+	return out, err
+}
+
+func formatTemplateValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// eval evaluates an expression.
+func (env *templateEnv) eval(expr string) (interface{}, error) {
+	p := &exprParser{s: expr, env: env}
+	v, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+	return v, nil
+}
+
+// field resolves a dotted field name in the object holding the template,
+// evaluating templates it refers to.
+func (env *templateEnv) field(name string) (interface{}, error) {
+	if env.visiting[name] {
+		return nil, fmt.Errorf("%v refers to itself", name)
+	}
+
+	var v interface{} = env.object
+	for _, key := range strings.Split(name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unknown name: %v", name)
+		}
+		if v, ok = m[key]; !ok {
+			return nil, fmt.Errorf("unknown name: %v", name)
+		}
+	}
+
+	if s, ok := v.(string); ok && strings.Contains(s, "{{") {
+		env.visiting[name] = true
+		defer delete(env.visiting, name)
+		return env.renderString(s)
+	}
+	return v, nil
+}
+
+// request resolves the request.* names.
+func (env *templateEnv) requestValue(name string) (interface{}, error) {
+	switch {
+	case name == "request.symbol":
+		if env.symbol != "" {
+			return env.symbol, nil
+		}
+		if len(env.symbols) == 0 {
+			return "", nil
+		}
+		return env.symbols[0], nil
+	case name == "request.symbols":
+		return strings.Join(env.symbols, ","), nil
+	case name == "request.path":
+		return env.request.URL.Path, nil
+	case strings.HasPrefix(name, "request.query."):
+		return env.request.URL.Query().Get(strings.TrimPrefix(name, "request.query.")), nil
+	}
+	return nil, fmt.Errorf("unknown name: %v", name)
+}
+
+// today resolves today [HH:MM [Zone]] to epoch seconds. Zones come from the
+// zone database of the host.
+func (env *templateEnv) today(clock, zone string) (interface{}, error) {
+	loc := time.UTC
+	if zone != "" {
+		var err error
+		loc, err = time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone: %v", zone)
+		}
+	}
+
+	var hour, min int
+	if clock != "" {
+		t, err := time.Parse("15:04", clock)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of day: %v", clock)
+		}
+		hour, min = t.Hour(), t.Minute()
+	}
+
+	now := env.now.In(loc)
+	return float64(time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, loc).Unix()), nil
+}
+
+// exprParser parses and evaluates arithmetic expressions:
+//
+//	expr  = term {("+" | "-") term}
+//	term  = unary {("*" | "/") unary}
+//	unary = "-" unary | "(" expr ")" | number [unit] | name
+type exprParser struct {
+	s   string
+	pos int
+	env *templateEnv
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// word reads up to the next space or parenthesis.
+func (p *exprParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" ()", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *exprParser) expr() (interface{}, error) {
+	v, err := p.term()
+	for err == nil {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			break
+		}
+		p.pos++
+		var w interface{}
+		if w, err = p.term(); err == nil {
+			v, err = arithmetic(op, v, w)
+		}
+	}
+	return v, err
+}
+
+func (p *exprParser) term() (interface{}, error) {
+	v, err := p.unary()
+	for err == nil {
+		op := p.peek()
+		if op != '*' && op != '/' {
+			break
+		}
+		p.pos++
+		var w interface{}
+		if w, err = p.unary(); err == nil {
+			v, err = arithmetic(op, v, w)
+		}
+	}
+	return v, err
+}
+
+func (p *exprParser) unary() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '-':
+		p.pos++
+		v, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic('-', 0.0, v)
+	case c == '(':
+		p.pos++
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return v, nil
+	case c >= '0' && c <= '9' || c == '.':
+		return p.number()
+	case c == 0:
+		return nil, fmt.Errorf("missing value")
+	}
+	return p.name()
+}
+
+func (p *exprParser) number() (interface{}, error) {
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// Durations are numbers of seconds.
+	if p.pos < len(p.s) {
+		if unit, ok := durationUnits[string(p.s[p.pos])]; ok {
+			p.pos++
+			f *= unit
+		}
+	}
+	return f, nil
+}
+
+func (p *exprParser) name() (interface{}, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if !(c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			break
+		}
+		p.pos++
+	}
+	name := p.s[start:p.pos]
+	if name == "" {
+		return nil, fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+
+	switch {
+	case name == "now":
+		return float64(p.env.now.Unix()), nil
+	case name == "today":
+		// today [HH:MM [Zone]]
+		var clock, zone string
+		if c := p.peek(); c >= '0' && c <= '9' {
+			clock = p.word()
+			if c := p.peek(); c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+				zone = p.word()
+			}
+		}
+		return p.env.today(clock, zone)
+	case strings.HasPrefix(name, "request."):
+		return p.env.requestValue(name)
+	}
+	return p.env.field(name)
+}
+
+func arithmetic(op byte, a, b interface{}) (interface{}, error) {
+	x, ok := a.(float64)
+	y, ok2 := b.(float64)
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("%c needs numbers, got %v and %v", op, a, b)
+	}
+
+	switch op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	}
+	if y == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	return x / y, nil
+}
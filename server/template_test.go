@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestTemplateExpressions(t *testing.T) {
+	now := time.Date(2026, 3, 10, 18, 0, 0, 0, time.UTC)
+	env := &templateEnv{
+		now:     now,
+		request: httptest.NewRequest("GET", "/v7/finance/quote?symbols=AAPL,SPY&region=US", nil),
+		symbols: []string{"AAPL", "SPY"},
+		object: map[string]interface{}{
+			"price":  100.0,
+			"nested": map[string]interface{}{"open": 90.0},
+			"change": "{{price - nested.open}}",
+			"loop":   "{{loop + 1}}",
+		},
+		visiting: map[string]bool{},
+	}
+
+	newYorkOpen := time.Date(2026, 3, 10, 9, 30, 0, 0, mustLoadLocation(t, "America/New_York"))
+	testCases := []struct {
+		expr string
+		want interface{}
+	}{
+		{"now", float64(now.Unix())},
+		{"now - 15m", float64(now.Add(-15 * time.Minute).Unix())},
+		{" now+1d ", float64(now.AddDate(0, 0, 1).Unix())},
+		{"today", float64(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC).Unix())},
+		{"today 09:30 America/New_York", float64(newYorkOpen.Unix())},
+		{"today 09:30 America/New_York - 1d", float64(newYorkOpen.Unix() - 86400)},
+		{"request.symbol", "AAPL"},
+		{"request.symbols", "AAPL,SPY"},
+		{"request.query.region", "US"},
+		{"price * 1.01", 101.0},
+		{"(price - 50) / 2", 25.0},
+		{"-price + 1", -99.0},
+		{"change * 2", 20.0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			v, err := env.eval(tc.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, v)
+		})
+	}
+
+	for _, expr := range []string{"unknown", "price +", "loop", "price / 0", "request.symbol * 2", "today 9am", "today 09:30 Nowhere/Zone", "(price", "price price"} {
+		_, err := env.eval(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	assert.NoError(t, err)
+	return loc
+}
+
+func TestTemplateRendering(t *testing.T) {
+	s := newTestServer(t)
+
+	patch := `{"regularMarketTime": "{{now - 15m}}", "shortName": "{{request.symbol}} Inc.", "regularMarketOpen": "{{regularMarketPrice - 1}}", "broken": "{{nope}}"}`
+	status, _ := doFixtureRequest(t, s, "PATCH", "/config/fixtures/yfin/quote/AAPL/POST", patch)
+	assert.Equal(t, http.StatusOK, status)
+
+	before := time.Now().Add(-15 * time.Minute).Unix()
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	assert.Equal(t, http.StatusOK, status)
+	quote := body["quoteResponse"].(map[string]interface{})["result"].([]interface{})[0].(map[string]interface{})
+	assert.InDelta(t, float64(before), quote["regularMarketTime"], 5)
+	assert.Equal(t, "AAPL Inc.", quote["shortName"])
+	assert.Equal(t, quote["regularMarketPrice"].(float64)-1, quote["regularMarketOpen"])
+	assert.Equal(t, "{{nope}}", quote["broken"])
+
+	// Static servers serve templates as they are.
+	s.Static = true
+	_, body = doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL")
+	quote = body["quoteResponse"].(map[string]interface{})["result"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "{{now - 15m}}", quote["regularMarketTime"])
+}
+
+func TestTemplateRenderingPerSymbol(t *testing.T) {
+	s := newTestServer(t)
+
+	patch := `{"shortName": "{{request.symbol}} Inc."}`
+	for _, symbol := range []string{"AAPL", "SPY"} {
+		status, _ := doFixtureRequest(t, s, "PATCH", "/config/fixtures/yfin/quote/"+symbol+"/POST", patch)
+		assert.Equal(t, http.StatusOK, status)
+	}
+
+	// Each quote takes its own symbol.
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AAPL,SPY")
+	assert.Equal(t, http.StatusOK, status)
+	result := body["quoteResponse"].(map[string]interface{})["result"].([]interface{})
+	assert.Len(t, result, 2)
+	for _, q := range result {
+		quote := q.(map[string]interface{})
+		assert.Equal(t, quote["symbol"].(string)+" Inc.", quote["shortName"])
+	}
+}
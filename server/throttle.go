@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	"github.com/piquette/finance-mock/utils"
+)
+
+// ThrottleHeader names the request header that throttles a single response,
+// e.g. Finance-Mock-Throttle: rate=1024, chunk=256, flush=100ms.
+const ThrottleHeader = "Finance-Mock-Throttle"
+
+// defaultChunkSize is the chunk size of throttles without one.
+const defaultChunkSize = 512
+
+// bandwidthThrottle slows down responses per route.
+type bandwidthThrottle struct {
+	mu     sync.Mutex
+	routes map[fixture.Path]fixture.Throttle
+}
+
+func newBandwidthThrottle() *bandwidthThrottle {
+	return &bandwidthThrottle{routes: make(map[fixture.Path]fixture.Throttle)}
+}
+
+// set configures the throttle of a route.
+func (b *bandwidthThrottle) set(path fixture.Path, config *fixture.Throttle) error {
+	c, err := validateThrottle(config)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.routes[path] = *c
+	return nil
+}
+
+// clear removes the throttle of a route.
+func (b *bandwidthThrottle) clear(path fixture.Path) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.routes, path)
+}
+
+// config lists the throttle of every configured route.
+func (b *bandwidthThrottle) config() map[fixture.Path]fixture.Throttle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	config := make(map[fixture.Path]fixture.Throttle, len(b.routes))
+	for path, throttle := range b.routes {
+		config[path] = throttle
+	}
+	return config
+}
+
+// wrap slows down the response writer of a throttled route. The request
+// header wins over the route's throttle.
+func (b *bandwidthThrottle) wrap(w http.ResponseWriter, r *http.Request, path fixture.Path) http.ResponseWriter {
+	var config *fixture.Throttle
+
+	if header := r.Header.Get(ThrottleHeader); header != "" {
+		c, err := parseThrottleHeader(header)
+		if err != nil {
+			utils.Log(Verbose, "Ignoring throttle header: %v", err)
+		} else {
+			config = c
+		}
+	}
+
+	if config == nil {
+		b.mu.Lock()
+		c, ok := b.routes[path]
+		if !ok {
+			c, ok = b.routes[allRoutes]
+		}
+		b.mu.Unlock()
+		if !ok {
+			return w
+		}
+		config = &c
+	}
+
+	return &throttledWriter{ResponseWriter: w, ctx: r.Context(), config: *config}
+}
+
+func validateThrottle(config *fixture.Throttle) (*fixture.Throttle, error) {
+	c := *config
+	if c.Rate < 0 || c.ChunkSize < 0 || c.FlushInterval < 0 {
+		return nil, fmt.Errorf("throttle settings must not be negative")
+	}
+	if c.Rate == 0 && c.ChunkSize == 0 {
+		return nil, fmt.Errorf("throttle needs a rate or a chunk size")
+	}
+	if c.ChunkSize == 0 {
+		c.ChunkSize = defaultChunkSize
+	}
+	return &c, nil
+}
+
+// parseThrottleHeader parses comma separated rate, chunk and flush settings.
+func parseThrottleHeader(header string) (*fixture.Throttle, error) {
+	var err error
+	config := &fixture.Throttle{}
+
+	for _, setting := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(setting), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid throttle setting: %v", setting)
+		}
+
+		switch kv[0] {
+		case "rate":
+			config.Rate, err = strconv.Atoi(kv[1])
+		case "chunk":
+			config.ChunkSize, err = strconv.Atoi(kv[1])
+		case "flush":
+			config.FlushInterval, err = fixture.ParseDuration(kv[1])
+		default:
+			err = fmt.Errorf("unknown throttle setting: %v", kv[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return validateThrottle(config)
+}
+
+// throttledWriter streams the body in chunks with chunked transfer encoding.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	config  fixture.Throttle
+	start   time.Time
+	written int
+}
+
+// Write streams data at the throttled rate.
+func (t *throttledWriter) Write(data []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	lastFlush := time.Now()
+
+	var n int
+	for len(data) > 0 {
+		size := t.config.ChunkSize
+		if size > len(data) {
+			size = len(data)
+		}
+
+		m, err := t.ResponseWriter.Write(data[:size])
+		n += m
+		t.written += m
+		if err != nil {
+			return n, err
+		}
+		data = data[size:]
+
+		if time.Since(lastFlush) >= time.Duration(t.config.FlushInterval) {
+			t.Flush()
+			lastFlush = time.Now()
+		}
+
+		if len(data) > 0 && !t.wait() {
+			return n, t.ctx.Err()
+		}
+	}
+
+	t.Flush()
+	return n, nil
+}
+
+// wait sleeps until the bytes written so far are due at the throttled rate.
+// It returns false if the request was cancelled in the meantime.
+func (t *throttledWriter) wait() bool {
+	if t.config.Rate == 0 {
+		return t.ctx.Err() == nil
+	}
+
+	due := t.start.Add(time.Duration(float64(t.written) / float64(t.config.Rate) * float64(time.Second)))
+	d := time.Until(due)
+	if d <= 0 {
+		return t.ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-t.ctx.Done():
+		return false
+	}
+}
+
+// Flush sends the buffered chunks to the client.
+func (t *throttledWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands over the connection for chaos modes.
+func (t *throttledWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := t.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection can't be hijacked")
+	}
+	return hj.Hijack()
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/piquette/finance-mock/fixture"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestParseThrottleHeader(t *testing.T) {
+	config, err := parseThrottleHeader("rate=1024, chunk=256, flush=100ms")
+	assert.NoError(t, err)
+	assert.Equal(t, &fixture.Throttle{
+		Rate:          1024,
+		ChunkSize:     256,
+		FlushInterval: fixture.Duration(100 * time.Millisecond),
+	}, config)
+
+	config, err = parseThrottleHeader("rate=10")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultChunkSize, config.ChunkSize)
+
+	for _, header := range []string{"flush=1s", "rate", "rate=fast", "speed=1", "rate=-1"} {
+		_, err = parseThrottleHeader(header)
+		assert.Error(t, err, header)
+	}
+}
+
+func TestThrottledResponse(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleRequest))
+	defer ts.Close()
+
+	// The chart is about 60kb, so at 400kb/s it takes 150ms.
+	req, _ := http.NewRequest("GET", ts.URL+"/v8/finance/chart/AAPL", nil)
+	req.Header.Set(ThrottleHeader, "rate=400000, chunk=4096")
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+
+	elapsed := time.Since(start)
+	expected := time.Duration(float64(len(body)-4096) / 400000 * float64(time.Second))
+	assert.True(t, elapsed >= expected, "took %v, expected %v", elapsed, expected)
+	assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+}
+
+func TestThrottleConfig(t *testing.T) {
+	s := newTestServer(t)
+
+	status, _ := doConfigRequest(t, s, "POST", "/config/throttle", url.Values{
+		"path":  {"/v7/finance/options"},
+		"rate":  {"2048"},
+		"flush": {"50ms"},
+	})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, fixture.Throttle{
+		Rate:          2048,
+		ChunkSize:     defaultChunkSize,
+		FlushInterval: fixture.Duration(50 * time.Millisecond),
+	}, s.routes().throttle.config()["/v7/finance/options"])
+
+	status, _ = doConfigRequest(t, s, "POST", "/config/throttle", url.Values{"path": {"/v7/finance/options"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = doConfigRequest(t, s, "DELETE", "/config/throttle?path=/v7/finance/options", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, s.routes().throttle.config())
+}
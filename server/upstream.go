@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// hopHeaders are not forwarded to the upstream.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	// Let the client negotiate and undo compression.
+	"Accept-Encoding",
+	SessionHeader,
+	ThrottleHeader,
+}
+
+// upstream is the real api that requests are forwarded to.
+type upstream struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func newUpstream(base string) (*upstream, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("upstream must be an http(s) url: %v", base)
+	}
+	return &upstream{base: u, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// forward sends a request to the upstream and reads the response.
+func (up *upstream) forward(r *http.Request) (*http.Response, []byte, error) {
+	u := *up.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+
+	req, err := http.NewRequest(r.Method, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(r.Context())
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+
+	resp, err := up.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
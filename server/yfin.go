@@ -3,9 +3,6 @@ package server
 import (
 	"fmt"
 	"net/http"
-	"net/url"
-	"path"
-	"regexp"
 	"strings"
 
 	"github.com/piquette/finance-mock/fixture"
@@ -17,48 +14,48 @@ import (
 type YFinService struct {
 	Service   *fixture.Service
 	Resources fixture.Resources
+	market    MarketState
 }
 
 // Handle validates a request and returns a response.
-func (y *YFinService) Handle(req *http.Request, rte *regexp.Regexp) (statusCode int, responseData interface{}) {
+func (y *YFinService) Handle(req *http.Request, match *RouteMatch) (statusCode int, responseData interface{}) {
 
-	// Parse query and build request data.
-	// -----------------------------------------
-	requestData, err := utils.ParseFormString(req.URL.RawQuery)
+	// Serve the fixtures snapshot and market state of the request.
+	y = &YFinService{Service: y.Service, Resources: y.Resources, market: Market}
+	if f := fixturesFrom(req.Context()); f != nil {
+		y.Resources = f.Resources[fixture.ServiceYFin]
+	}
+	if m := marketFrom(req.Context()); m != "" {
+		y.market = m
+	}
+
+	// Determine which YFin resource is requested.
+	op := match.Operation
+	if op == nil {
+		utils.Log(Verbose, "Couldn't figure out what yfin operation was requested")
+		return yfin.CreateInternalServerError()
+	}
+
+	requestData, perr, err := requestParams(req, op)
 	if err != nil {
-		utils.Log(Verbose, "Couldn't parse url query: %v", err)
 		return yfin.CreateInternalServerError()
 	}
+	if perr != nil {
+		return perr.response()
+	}
 
-	// Determine which YFin resource is requested.
-	for p, op := range y.Service.Paths {
-		// Match path.
-		if rte.MatchString(string(p)) {
-			// TODO: validate params properly.
-			switch op.ResourceID {
-			case fixture.YFinQuotes:
-				{
-					return y.quote(requestData)
-				}
-			case fixture.YFinChart:
-				{
-					symbol, err := url.PathUnescape(path.Base(req.URL.Path))
-					if err != nil {
-						utils.Log(Verbose, "Couldn't parse chart symbol")
-						break
-					}
-					return y.chart(symbol, requestData)
-				}
-			case fixture.YFinOptions:
-				{
-					symbol, err := url.PathUnescape(path.Base(req.URL.Path))
-					if err != nil {
-						utils.Log(Verbose, "Couldn't parse options symbol")
-						break
-					}
-					return y.options(symbol, requestData)
-				}
-			}
+	switch op.ResourceID {
+	case fixture.YFinQuotes:
+		{
+			return y.quote(requestData)
+		}
+	case fixture.YFinChart:
+		{
+			return y.chart(match.symbol(), requestData)
+		}
+	case fixture.YFinOptions:
+		{
+			return y.options(match.symbol(), requestData)
 		}
 	}
 
@@ -69,25 +66,26 @@ func (y *YFinService) Handle(req *http.Request, rte *regexp.Regexp) (statusCode
 func (y *YFinService) quote(requestData map[string]interface{}) (statusCode int, responseData interface{}) {
 	utils.Log(Verbose, "Retrieving quote resource.")
 
-	s := requestData["symbols"]
-
-	if s == nil {
-		return yfin.CreateMissingSymbolsError()
-	}
-
-	symbolList := strings.Split(s.(string), ",")
-	resourceTree := y.Resources[fixture.YFinQuotes].(map[string]interface{})
+	// Symbols are a required list in the spec.
+	symbolList := listParam(requestData, "symbols")
+	resourceTree, _ := y.Resources[fixture.YFinQuotes].(map[string]interface{})
 
 	quotes := []interface{}{}
 	for _, symbol := range symbolList {
 
 		r := resourceTree[symbol]
 		if r == nil {
+			// Fall back to an option chain entry.
+			occ, contract := y.optionContract(symbol)
+			if contract == nil {
+				continue
+			}
+			quotes = append(quotes, yfin.CreateOptionQuote(occ, contract, strings.ToUpper(string(y.market))))
 			continue
 		}
 
 		quoteMap := r.(map[string]interface{})
-		q := quoteMap[strings.ToUpper(string(Market))]
+		q := quoteMap[strings.ToUpper(string(y.market))]
 		if q == nil {
 			msg := fmt.Sprintf("Could not find quote for symbol: %s in map, continuing anyway.", symbol)
 			utils.Log(Verbose, msg)
@@ -102,35 +100,74 @@ func (y *YFinService) chart(symbol string, requestData map[string]interface{}) (
 
 	utils.Log(Verbose, "Retrieving chart resource for symbol: "+symbol)
 
-	// TODO: validate properties...
-
-	resourceTree := y.Resources[fixture.YFinChart].(map[string]interface{})
+	resourceTree, _ := y.Resources[fixture.YFinChart].(map[string]interface{})
 	r := resourceTree[symbol]
 	if r == nil {
+		// Fall back to an option chain entry.
+		occ, contract := y.optionContract(symbol)
+		if contract != nil {
+			return yfin.CreateChart(yfin.CreateOptionChart(occ, contract))
+		}
 		r = resourceTree["error"]
 	}
-	chartMap := r.(map[string]interface{})
+	chartMap, ok := r.(map[string]interface{})
+	if !ok {
+		return yfin.CreateChartNotFoundError()
+	}
 
 	return yfin.CreateChart(chartMap)
 }
 
+// optionContract finds a contract in the option chain fixture of its
+// underlying symbol.
+func (y *YFinService) optionContract(symbol string) (*yfin.OCCSymbol, map[string]interface{}) {
+	occ, err := yfin.ParseOCC(symbol)
+	if err != nil {
+		return nil, nil
+	}
+
+	tree, _ := y.Resources[fixture.YFinOptions].(map[string]interface{})
+	optionTree, _ := tree[occ.Underlying].(map[string]interface{})
+	chain, _ := optionTree["chain"].(map[string]interface{})
+	if chain == nil {
+		utils.Log(Verbose, "Option chain for underlying not found: "+occ.Underlying)
+		return nil, nil
+	}
+
+	side := "calls"
+	if occ.Type == yfin.OptionPut {
+		side = "puts"
+	}
+
+	expirations, _ := chain["options"].([]interface{})
+	for _, e := range expirations {
+		expiration, _ := e.(map[string]interface{})
+		contracts, _ := expiration[side].([]interface{})
+		for _, c := range contracts {
+			contract, _ := c.(map[string]interface{})
+			if contract != nil && contract["contractSymbol"] == occ.Symbol {
+				return occ, contract
+			}
+		}
+	}
+
+	utils.Log(Verbose, "Contract not found in option chain: "+occ.Symbol)
+	return nil, nil
+}
+
 func (y *YFinService) options(symbol string, requestData map[string]interface{}) (statusCode int, responseData interface{}) {
 	utils.Log(Verbose, "Retrieving options resource for symbol: "+symbol)
 
-	tree := y.Resources[fixture.YFinOptions].(map[string]interface{})
-	optionTree := tree[symbol]
-	if tree == nil {
+	tree, _ := y.Resources[fixture.YFinOptions].(map[string]interface{})
+	optionMap, ok := tree[symbol].(map[string]interface{})
+	if !ok {
 		utils.Log(Verbose, "Options for symbol not found.")
 		return yfin.CreateOptions(nil)
 	}
-	optionMap := optionTree.(map[string]interface{})
 
 	format := "chain"
-	straddle := requestData["straddle"]
-	if straddle != nil {
-		if straddle.(string) == "true" {
-			format = "straddle"
-		}
+	if boolParam(requestData, "straddle") {
+		format = "straddle"
 	}
 
 	return yfin.CreateOptions(optionMap[format])
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestQuoteOptionContract(t *testing.T) {
+	s := newTestServer(t)
+
+	// The put only exists in the AMD option chain fixture.
+	status, body := doRequest(t, s, "GET", "/v7/finance/quote?symbols=AMD180720P00003000")
+	assert.Equal(t, http.StatusOK, status)
+
+	quotes := body["quoteResponse"].(map[string]interface{})["result"].([]interface{})
+	assert.Len(t, quotes, 1)
+	q := quotes[0].(map[string]interface{})
+	assert.Equal(t, "OPTION", q["quoteType"])
+	assert.Equal(t, "AMD180720P00003000", q["symbol"])
+	assert.Equal(t, "AMD", q["underlyingSymbol"])
+	assert.Equal(t, 3.0, q["strike"])
+	assert.Equal(t, 1532044800.0, q["expireDate"])
+
+	// Unlisted contracts are skipped.
+	status, body = doRequest(t, s, "GET", "/v7/finance/quote?symbols=AMD180720P09999000")
+	assert.Equal(t, http.StatusOK, status)
+	quotes = body["quoteResponse"].(map[string]interface{})["result"].([]interface{})
+	assert.Len(t, quotes, 0)
+}
+
+func TestChartOptionContract(t *testing.T) {
+	s := newTestServer(t)
+
+	status, body := doRequest(t, s, "GET", "/v8/finance/chart/AMD180720P00003000")
+	assert.Equal(t, http.StatusOK, status)
+
+	charts := body["chart"].(map[string]interface{})["result"].([]interface{})
+	meta := charts[0].(map[string]interface{})["meta"].(map[string]interface{})
+	assert.Equal(t, "OPTION", meta["instrumentType"])
+	assert.Equal(t, "AMD180720P00003000", meta["symbol"])
+}
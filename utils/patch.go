@@ -0,0 +1,28 @@
+package utils
+
+// MergePatch applies a json merge patch (RFC 7386) to a decoded json value.
+// The target is left untouched, maps along the patched paths are copied.
+func MergePatch(target, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = map[string]interface{}{}
+	}
+
+	result := make(map[string]interface{}, len(targetMap))
+	for k, v := range targetMap {
+		result[k] = v
+	}
+	for k, v := range patchMap {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		result[k] = MergePatch(result[k], v)
+	}
+	return result
+}
@@ -20,3 +20,29 @@ func Contains(s []string, e string) bool {
 	}
 	return false
 }
+
+// NormalizeYAML converts the maps decoded by yaml into json encodable ones.
+// It returns a copy, so v may be in use elsewhere.
+func NormalizeYAML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = NormalizeYAML(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[k] = NormalizeYAML(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, val := range t {
+			l[i] = NormalizeYAML(val)
+		}
+		return l
+	}
+	return v
+}
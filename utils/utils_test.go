@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestNormalizeYAML(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{"1": "b"},
+		"c": []interface{}{map[string]interface{}{"d": 1}},
+	}, NormalizeYAML(map[interface{}]interface{}{
+		"a": map[interface{}]interface{}{1: "b"},
+		"c": []interface{}{map[interface{}]interface{}{"d": 1}},
+	}))
+
+	// The input is left alone.
+	in := map[string]interface{}{"a": []interface{}{map[interface{}]interface{}{"b": 1}}}
+	out := NormalizeYAML(in).(map[string]interface{})
+	out["a"].([]interface{})[0] = nil
+	assert.Equal(t, map[interface{}]interface{}{"b": 1}, in["a"].([]interface{})[0])
+}
@@ -0,0 +1,143 @@
+package yfin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// OptionCall is a call contract.
+	OptionCall OptionType = "C"
+	// OptionPut is a put contract.
+	OptionPut OptionType = "P"
+)
+
+// occPattern matches an OCC contract symbol, e.g. AMD180720C00003000. The root
+// may be padded to six characters with spaces as in the raw OCC format.
+var occPattern = regexp.MustCompile(`\A([A-Z0-9.]{1,6}) *(\d{6})([CP])(\d{8})\z`)
+
+// OptionType is the right of an option contract.
+type OptionType string
+
+// OCCSymbol is a parsed OCC option contract symbol.
+type OCCSymbol struct {
+	Symbol     string
+	Underlying string
+	Expiration time.Time
+	Type       OptionType
+	Strike     float64
+}
+
+// ParseOCC parses an OCC option contract symbol.
+func ParseOCC(symbol string) (*OCCSymbol, error) {
+	m := occPattern.FindStringSubmatch(strings.ToUpper(symbol))
+	if m == nil {
+		return nil, fmt.Errorf("not an occ symbol: %s", symbol)
+	}
+
+	expiration, err := time.Parse("060102", m[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid occ expiration: %s", m[2])
+	}
+
+	strike, err := strconv.Atoi(m[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid occ strike: %s", m[4])
+	}
+
+	return &OCCSymbol{
+		Symbol:     m[1] + m[2] + m[3] + m[4],
+		Underlying: m[1],
+		Expiration: expiration,
+		Type:       OptionType(m[3]),
+		Strike:     float64(strike) / 1000,
+	}, nil
+}
+
+// String formats the contract the way yahoo does, without root padding.
+func (o *OCCSymbol) String() string {
+	return fmt.Sprintf("%s%s%s%08d", o.Underlying, o.Expiration.Format("060102"),
+		o.Type, int64(o.Strike*1000+0.5))
+}
+
+// ShortName formats the contract description, e.g. AMD Jul 2018 3.000 call.
+func (o *OCCSymbol) ShortName() string {
+	right := "call"
+	if o.Type == OptionPut {
+		right = "put"
+	}
+	return fmt.Sprintf("%s %s %.3f %s", o.Underlying, o.Expiration.Format("Jan 2006"), o.Strike, right)
+}
+
+// CreateOptionQuote builds a quote for a contract from its option chain entry.
+func CreateOptionQuote(occ *OCCSymbol, contract map[string]interface{}, marketState string) map[string]interface{} {
+	q := map[string]interface{}{
+		"language":                   "en-US",
+		"region":                     "US",
+		"quoteType":                  "OPTION",
+		"currency":                   contract["currency"],
+		"exchange":                   "OPR",
+		"fullExchangeName":           "OPR",
+		"shortName":                  occ.ShortName(),
+		"marketState":                marketState,
+		"market":                     "us24_market",
+		"regularMarketPrice":         contract["lastPrice"],
+		"regularMarketTime":          contract["lastTradeDate"],
+		"regularMarketChange":        contract["change"],
+		"regularMarketChangePercent": contract["percentChange"],
+		"regularMarketVolume":        contract["volume"],
+		"bid":                        contract["bid"],
+		"ask":                        contract["ask"],
+		"strike":                     contract["strike"],
+		"openInterest":               contract["openInterest"],
+		"expireDate":                 contract["expiration"],
+		"underlyingSymbol":           occ.Underlying,
+		"exchangeTimezoneName":       "America/New_York",
+		"tradeable":                  false,
+		"priceHint":                  2,
+		"symbol":                     occ.Symbol,
+	}
+
+	price, okPrice := contract["lastPrice"].(float64)
+	change, okChange := contract["change"].(float64)
+	if okPrice && okChange {
+		q["regularMarketPreviousClose"] = price - change
+	}
+
+	return q
+}
+
+// CreateOptionChart builds a single bar chart for a contract from its option
+// chain entry.
+func CreateOptionChart(occ *OCCSymbol, contract map[string]interface{}) map[string]interface{} {
+	price := contract["lastPrice"]
+	meta := map[string]interface{}{
+		"currency":             contract["currency"],
+		"symbol":               occ.Symbol,
+		"exchangeName":         "OPR",
+		"instrumentType":       "OPTION",
+		"exchangeTimezoneName": "America/New_York",
+		"dataGranularity":      "1d",
+		"regularMarketPrice":   price,
+		"regularMarketTime":    contract["lastTradeDate"],
+	}
+
+	bar := map[string]interface{}{
+		"open":   []interface{}{price},
+		"high":   []interface{}{price},
+		"low":    []interface{}{price},
+		"close":  []interface{}{price},
+		"volume": []interface{}{contract["volume"]},
+	}
+
+	return map[string]interface{}{
+		"meta":      meta,
+		"timestamp": []interface{}{contract["lastTradeDate"]},
+		"indicators": map[string]interface{}{
+			"quote": []interface{}{bar},
+		},
+	}
+}
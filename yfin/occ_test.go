@@ -0,0 +1,32 @@
+package yfin
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestParseOCC(t *testing.T) {
+	occ, err := ParseOCC("AMD180720C00003000")
+	assert.NoError(t, err)
+	assert.Equal(t, "AMD", occ.Underlying)
+	assert.Equal(t, time.Date(2018, 7, 20, 0, 0, 0, 0, time.UTC), occ.Expiration)
+	assert.Equal(t, OptionCall, occ.Type)
+	assert.Equal(t, 3.0, occ.Strike)
+	assert.Equal(t, "AMD180720C00003000", occ.String())
+	assert.Equal(t, "AMD Jul 2018 3.000 call", occ.ShortName())
+
+	// Padded root as in the raw OCC format.
+	occ, err = ParseOCC("SPY   180720P00270500")
+	assert.NoError(t, err)
+	assert.Equal(t, "SPY", occ.Underlying)
+	assert.Equal(t, OptionPut, occ.Type)
+	assert.Equal(t, 270.5, occ.Strike)
+	assert.Equal(t, "SPY180720P00270500", occ.Symbol)
+
+	for _, symbol := range []string{"AAPL", "AMD180720X00003000", "AMD181320C00003000", "AMD180720C3000"} {
+		_, err = ParseOCC(symbol)
+		assert.Error(t, err, symbol)
+	}
+}
@@ -1,18 +1,41 @@
 package yfin
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+)
 
 const (
 	internalErrorDescription = "An internal error occurred."
 	internalErrorInfo        = "invalid-request"
 
-	symbolsErrorDescription = "Missing value for the \"symbols\" argument"
-	symbolsErrorInfo        = "argument-error"
+	missingArgumentDescription = "Missing value for the \"%s\" argument"
+	unknownArgumentDescription = "Unrecognized argument \"%s\""
+	invalidArgumentDescription = "Invalid value for the \"%s\" argument: %s"
+	argumentErrorInfo          = "argument-error"
 
 	chartErrorDescription = "No data found, symbol may be delisted"
 	chartErrorInfo        = "Not Found"
+
+	serverErrorInfo = "server-error"
 )
 
+// edgeErrorPage is the page yahoo's edge servers send instead of api errors.
+const edgeErrorPage = `<!DOCTYPE html>
+<html lang="en-us"><head>
+<meta http-equiv="content-type" content="text/html; charset=UTF-8">
+<meta charset="utf-8">
+<title>Yahoo</title>
+</head>
+<body>
+<div id="bd"><h1>Will be right back...</h1>
+<p>Thank you for your patience.</p>
+<p>Our engineers are working quickly to resolve the issue.</p>
+<p>Error %d</p>
+</div>
+</body></html>
+`
+
 // Error internal error information structure.
 type Error struct {
 	Info        string `json:"code"`
@@ -63,6 +86,11 @@ func CreateChart(chart interface{}) (int, *ChartResponse) {
 	return http.StatusOK, &ChartResponse{c}
 }
 
+// CreateChartNotFoundError creates the chart error for unknown symbols.
+func CreateChartNotFoundError() (int, *ChartResponse) {
+	return http.StatusNotFound, &ChartResponse{createAPIError(chartErrorInfo, chartErrorDescription).Response}
+}
+
 // CreateOptions creates a valid options response.
 func CreateOptions(options interface{}) (int, *OptionsResponse) {
 	o := &Response{
@@ -72,9 +100,44 @@ func CreateOptions(options interface{}) (int, *OptionsResponse) {
 	return http.StatusOK, &OptionsResponse{o}
 }
 
-// CreateMissingSymbolsError creates an missing argument error for API issues.
-func CreateMissingSymbolsError() (int, *ErrorResponse) {
-	return http.StatusBadRequest, createAPIError(symbolsErrorInfo, symbolsErrorDescription)
+// CreateMissingArgumentError creates the argument error for a missing parameter.
+func CreateMissingArgumentError(name string) (int, *ErrorResponse) {
+	return CreateArgumentError(fmt.Sprintf(missingArgumentDescription, name))
+}
+
+// CreateUnknownArgumentError creates the argument error for a parameter the
+// endpoint doesn't take.
+func CreateUnknownArgumentError(name string) (int, *ErrorResponse) {
+	return CreateArgumentError(fmt.Sprintf(unknownArgumentDescription, name))
+}
+
+// CreateInvalidArgumentError creates the argument error for a parameter value
+// the endpoint doesn't take.
+func CreateInvalidArgumentError(name, reason string) (int, *ErrorResponse) {
+	return CreateArgumentError(fmt.Sprintf(invalidArgumentDescription, name, reason))
+}
+
+// CreateArgumentError creates an argument error for API issues.
+func CreateArgumentError(description string) (int, *ErrorResponse) {
+	return http.StatusBadRequest, createAPIError(argumentErrorInfo, description)
+}
+
+// CreateServerError creates an error envelope for the given 5xx status.
+func CreateServerError(status int) (int, *ErrorResponse) {
+	if status == http.StatusInternalServerError {
+		return CreateInternalServerError()
+	}
+	return status, createAPIError(serverErrorInfo, http.StatusText(status))
+}
+
+// CreateTooManyRequests creates the body yahoo sends to throttled clients.
+func CreateTooManyRequests() (int, []byte) {
+	return http.StatusTooManyRequests, []byte("Too Many Requests\r\n")
+}
+
+// CreateEdgeErrorPage creates the html error page for the given 5xx status.
+func CreateEdgeErrorPage(status int) (int, []byte) {
+	return status, []byte(fmt.Sprintf(edgeErrorPage, status))
 }
 
 // CreateInternalServerError creates an internal server error for API issues.